@@ -0,0 +1,240 @@
+package redisgo
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by Pool.Get immediately while the circuit breaker is open, see WithBreaker
+var ErrCircuitOpen = errors.New("redisgo: circuit breaker is open")
+
+// BreakerState represents the state of Breaker
+type BreakerState int
+
+const (
+	// StateClosed lets all calls pass, and opens the breaker if the failure rate is too high
+	StateClosed BreakerState = iota
+
+	// StateOpen rejects all calls with ErrCircuitOpen until the open timeout elapsed
+	StateOpen
+
+	// StateHalfOpen lets limited calls pass as probes,
+	// the breaker is closed if they succeed, or opened again if any of them fails
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// number of buckets of the sliding window
+const breakerBuckets = 10
+
+type breakerBucket struct {
+	start time.Time
+	ok    int
+	fail  int
+}
+
+// Breaker is a circuit breaker driven by the recent failure rate of a Pool.
+//
+// A call is a borrow of a conn from Pool.Get to PoolConn.Close rather than a command,
+// it fails if Pool.Get fails to dial or the conn is broken by transport errors when it's returned,
+// no matter how many commands are done with the conn. Errors replied by redis are not failures.
+type Breaker struct {
+	window      time.Duration
+	rate        float64
+	minreqs     int
+	opentimeout time.Duration
+	probes      int
+	statefunc   func(from, to BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	gen      uint64 // incr on every state change, results of previous states are ignored
+	buckets  [breakerBuckets]breakerBucket
+	cur      int       // index of the latest bucket
+	since    time.Time // time of the last state change
+	inflight int       // probes in half-open state
+	passed   int       // succeeded probes in half-open state
+
+	nowfunc func() time.Time
+}
+
+// BreakerOption represents a breaker option
+type BreakerOption func(b *Breaker)
+
+// WithBreakerWindow sets the sliding window of counting failures, default: 10s.
+func WithBreakerWindow(d time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		b.window = d
+	}
+}
+
+// WithBreakerFailureRate opens the breaker if the failure rate within the window reaches rate,
+// and there are at least minreqs calls. default: 0.5, 20
+func WithBreakerFailureRate(rate float64, minreqs int) BreakerOption {
+	return func(b *Breaker) {
+		b.rate = rate
+		b.minreqs = minreqs
+	}
+}
+
+// WithBreakerOpenTimeout sets the duration of the open state before probing, default: 5s.
+func WithBreakerOpenTimeout(d time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		b.opentimeout = d
+	}
+}
+
+// WithBreakerProbes sets the number of probes in the half-open state, default: 1.
+// At most n calls pass at the same time, and the breaker is closed after n of them succeed.
+// Probes not done within the open timeout, like conns never returned to the pool, are abandoned
+// and new probes are allowed.
+func WithBreakerProbes(n int) BreakerOption {
+	return func(b *Breaker) {
+		b.probes = n
+	}
+}
+
+// WithBreakerStateFunc sets fn for reporting state changes.
+// fn is called with the lock of the breaker held, it must not block or call the breaker.
+func WithBreakerStateFunc(fn func(from, to BreakerState)) BreakerOption {
+	return func(b *Breaker) {
+		b.statefunc = fn
+	}
+}
+
+// NewBreaker creates a instance of Breaker, which is used by WithBreaker
+func NewBreaker(ops ...BreakerOption) *Breaker {
+	b := &Breaker{
+		window:      10 * time.Second,
+		rate:        0.5,
+		minreqs:     20,
+		opentimeout: 5 * time.Second,
+		probes:      1,
+	}
+	for _, op := range ops {
+		op(b)
+	}
+	if b.probes <= 0 {
+		b.probes = 1
+	}
+	b.nowfunc = time.Now
+	return b
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timeout(b.nowfunc())
+	return b.state
+}
+
+// setstate changes state and drops counters of the previous state, b.mu must be held
+func (b *Breaker) setstate(s BreakerState, now time.Time) {
+	from := b.state
+	b.state = s
+	b.gen++
+	b.buckets = [breakerBuckets]breakerBucket{}
+	b.cur = 0
+	b.inflight = 0
+	b.passed = 0
+	b.since = now
+	if b.statefunc != nil && from != s {
+		b.statefunc(from, s)
+	}
+}
+
+// timeout changes state from open to half-open if the open timeout elapsed,
+// or starts probing again if all probes are taken and not done within the open timeout.
+// b.mu must be held
+func (b *Breaker) timeout(now time.Time) {
+	switch {
+	case now.Sub(b.since) < b.opentimeout:
+	case b.state == StateOpen:
+		b.setstate(StateHalfOpen, now)
+	case b.state == StateHalfOpen && b.inflight > 0 && b.inflight+b.passed >= b.probes:
+		b.setstate(StateHalfOpen, now) // results of abandoned probes are ignored by gen
+	}
+}
+
+// allow returns the generation of the call which is passed to done,
+// or ErrCircuitOpen if the call is rejected.
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timeout(b.nowfunc())
+	switch b.state {
+	case StateOpen:
+		return 0, ErrCircuitOpen
+	case StateHalfOpen:
+		if b.inflight+b.passed >= b.probes {
+			return 0, ErrCircuitOpen
+		}
+		b.inflight++
+	}
+	return b.gen, nil
+}
+
+// cancel releases a call of gen without counting it, like calls canceled by ctx
+func (b *Breaker) cancel(gen uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen == b.gen && b.state == StateHalfOpen {
+		b.inflight--
+	}
+}
+
+// done reports the result of a call of gen
+func (b *Breaker) done(gen uint64, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		return
+	}
+	now := b.nowfunc()
+	switch b.state {
+	case StateHalfOpen:
+		b.inflight--
+		if !ok {
+			b.setstate(StateOpen, now)
+			return
+		}
+		if b.passed++; b.passed >= b.probes {
+			b.setstate(StateClosed, now)
+		}
+	case StateClosed:
+		bk := &b.buckets[b.cur]
+		if now.Sub(bk.start) >= b.window/breakerBuckets {
+			b.cur = (b.cur + 1) % breakerBuckets
+			bk = &b.buckets[b.cur]
+			*bk = breakerBucket{start: now}
+		}
+		if ok {
+			bk.ok++
+			return
+		}
+		bk.fail++
+		total, fails := 0, 0
+		for _, bk := range b.buckets {
+			if now.Sub(bk.start) < b.window {
+				total += bk.ok + bk.fail
+				fails += bk.fail
+			}
+		}
+		if total >= b.minreqs && float64(fails) >= b.rate*float64(total) {
+			b.setstate(StateOpen, now)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package redisgo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	var now time.Time
+	var changes []string
+	b := NewBreaker(
+		WithBreakerWindow(10*time.Second),
+		WithBreakerFailureRate(0.5, 4),
+		WithBreakerOpenTimeout(time.Second),
+		WithBreakerProbes(2),
+		WithBreakerStateFunc(func(from, to BreakerState) {
+			changes = append(changes, from.String()+"->"+to.String())
+		}))
+	b.nowfunc = func() time.Time { return now }
+
+	call := func(ok bool) error {
+		gen, err := b.allow()
+		if err == nil {
+			b.done(gen, ok)
+		}
+		return err
+	}
+	call(false)
+	call(false)
+	call(true)
+	if b.State() != StateClosed { // less than minreqs
+		t.Fatal(b.State())
+	}
+	now = now.Add(11 * time.Second) // out of window
+	call(false)
+	call(true)
+	call(true)
+	call(false)
+	if b.State() != StateOpen {
+		t.Fatal(b.State())
+	}
+	if err := call(true); err != ErrCircuitOpen {
+		t.Fatal(err)
+	}
+
+	// half-open, a failed probe opens it again
+	now = now.Add(time.Second)
+	g0, err0 := b.allow()
+	g1, err1 := b.allow()
+	_, err2 := b.allow()
+	if err0 != nil || err1 != nil || err2 != ErrCircuitOpen {
+		t.Fatal(err0, err1, err2)
+	}
+	b.done(g0, true)
+	b.done(g1, false)
+	if b.State() != StateOpen {
+		t.Fatal(b.State())
+	}
+
+	// canceled probes do not count
+	now = now.Add(time.Second)
+	g0, _ = b.allow()
+	g1, _ = b.allow()
+	b.cancel(g1)
+	g1, _ = b.allow()
+	b.done(g0, true)
+	b.done(g1, true)
+	if b.State() != StateClosed {
+		t.Fatal(b.State())
+	}
+	b.done(g0, false) // from previous states, ignored
+	if b.State() != StateClosed {
+		t.Fatal(b.State())
+	}
+
+	expect := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if len(changes) != len(expect) {
+		t.Fatal(changes)
+	}
+	for i := range expect {
+		if changes[i] != expect[i] {
+			t.Fatal(changes)
+		}
+	}
+}
+
+func TestBreakerAbandonedProbe(t *testing.T) {
+	var now time.Time
+	b := NewBreaker(WithBreakerFailureRate(0.5, 1), WithBreakerOpenTimeout(time.Second))
+	b.nowfunc = func() time.Time { return now }
+	gen, _ := b.allow()
+	b.done(gen, false)
+
+	now = now.Add(time.Second)
+	g0, err := b.allow() // never done, like a conn not returned
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second - 1)
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Fatal(err)
+	}
+	now = now.Add(1)
+	g1, err := b.allow()
+	if err != nil || b.State() != StateHalfOpen {
+		t.Fatal(err, b.State())
+	}
+	b.done(g0, false) // abandoned, ignored
+	b.done(g1, true)
+	if b.State() != StateClosed {
+		t.Fatal(b.State())
+	}
+}
+
+func TestPoolBreaker(t *testing.T) {
+	var now time.Time
+	var dials int
+	dialerr := errors.New("connection refused")
+	dialfunc := func(ctx context.Context) (*Conn, error) {
+		dials++
+		if dialerr != nil {
+			return nil, dialerr
+		}
+		return dialpipe(ctx)
+	}
+	ctx := context.Background()
+	b := NewBreaker(WithBreakerFailureRate(0.5, 2), WithBreakerOpenTimeout(time.Second))
+	b.nowfunc = func() time.Time { return now }
+	p := NewPool(dialfunc, WithBreaker(b))
+
+	p.Get(ctx)
+	p.Get(ctx)
+	if b.State() != StateOpen || dials != 2 {
+		t.Fatal(b.State(), dials)
+	}
+	if _, err := p.Get(ctx); err != ErrCircuitOpen || dials != 2 {
+		t.Fatal(err, dials)
+	}
+
+	// probe by Get and Do
+	now = now.Add(time.Second)
+	dialerr = nil
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(ctx); err != ErrCircuitOpen {
+		t.Fatal(err)
+	}
+	if b, err := c.DoBytes("ECHO", "x"); err != nil || string(b) != "x" {
+		t.Fatal(string(b), err)
+	}
+	c.Close()
+	if b.State() != StateClosed {
+		t.Fatal(b.State())
+	}
+
+	// transport errors of Do
+	for i := 0; i < 2; i++ {
+		c, err = p.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Conn.conn.Close()
+		if _, err := c.Do("ECHO", "x"); err == nil {
+			t.Fatal("expect err")
+		}
+		c.Close()
+	}
+	if b.State() != StateOpen || p.Active() != 0 {
+		t.Fatal(b.State(), p.Active())
+	}
+
+	// canceled ctx is not a failure
+	now = now.Add(time.Second)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := p.Get(canceled); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if c, err = p.Get(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if b.State() != StateClosed {
+		t.Fatal(b.State())
+	}
+}
+
+func TestPoolBreakerDialBackoff(t *testing.T) {
+	dialfunc := func(ctx context.Context) (*Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	ctx := context.Background()
+	b := NewBreaker(WithBreakerFailureRate(0.5, 2))
+	p := NewPool(dialfunc, WithBreaker(b), WithDialBackoff(time.Minute, time.Minute))
+	if _, err := p.Get(ctx); err == nil || errors.Is(err, ErrDialBackoff) {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := p.Get(ctx); !errors.Is(err, ErrDialBackoff) {
+			t.Fatal(err)
+		}
+	}
+	if b.State() != StateClosed {
+		t.Fatal(b.State())
+	}
+}
@@ -0,0 +1,172 @@
+package redisgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Codec converts values to bytes stored in redis and back
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec encodes values with encoding/json
+	JSONCodec Codec = jsonCodec{}
+
+	// GobCodec encodes values with encoding/gob, each value carries its own type info
+	GobCodec Codec = gobCodec{}
+
+	// RawCodec stores []byte and string as they are,
+	// Unmarshal accepts *[]byte and *string, and the bytes are copied.
+	RawCodec Codec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)   { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(b []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("redisgo: RawCodec can not marshal %T", v)
+}
+
+func (rawCodec) Unmarshal(b []byte, v interface{}) error {
+	switch v := v.(type) {
+	case *[]byte:
+		*v = append([]byte(nil), b...)
+		return nil
+	case *string:
+		*v = string(b)
+		return nil
+	}
+	return fmt.Errorf("redisgo: RawCodec can not unmarshal into %T", v)
+}
+
+// header bytes of gzipCodec, part of the stable wire format
+const (
+	headerPlain byte = 0x00
+	headerGzip  byte = 0x01
+)
+
+var errEmptyPayload = errors.New("redisgo: empty payload without header")
+
+// ErrGzipTooLarge is returned by Unmarshal of NewGzipCodec if the value decompressed is too large, see WithGzipMaxSize
+var ErrGzipTooLarge = errors.New("redisgo: gzip value too large")
+
+// GzipOption represents options of NewGzipCodec
+type GzipOption func(g *gzipCodec)
+
+// WithGzipMaxSize limits the size of decompressed values for Unmarshal, default: 512MB.
+func WithGzipMaxSize(n int64) GzipOption {
+	return func(g *gzipCodec) {
+		g.maxsize = n
+	}
+}
+
+// NewGzipCodec returns a Codec which compresses values of c with gzip if they're larger than threshold bytes.
+//
+// The wire format is stable, every payload starts with a header byte:
+//
+//	0x00: the rest is the value encoded by c, not compressed
+//	0x01: the rest is a gzip stream (RFC 1952) of the value encoded by c
+//
+// Other header bytes are reserved and fail Unmarshal.
+// The value is stored uncompressed if compressing doesn't make it smaller.
+func NewGzipCodec(c Codec, threshold int, ops ...GzipOption) Codec {
+	g := &gzipCodec{c: c, threshold: threshold, maxsize: 512 << 20}
+	for _, op := range ops {
+		op(g)
+	}
+	return g
+}
+
+type gzipCodec struct {
+	c         Codec
+	threshold int
+	maxsize   int64
+}
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+func (g *gzipCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := g.c.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > g.threshold {
+		var buf bytes.Buffer
+		buf.Grow(len(b) / 2)
+		buf.WriteByte(headerGzip)
+		w := gzipWriterPool.Get().(*gzip.Writer)
+		w.Reset(&buf)
+		w.Write(b) // never fails with bytes.Buffer
+		w.Close()
+		gzipWriterPool.Put(w)
+		if buf.Len() < len(b)+1 {
+			return buf.Bytes(), nil
+		}
+	}
+	ret := make([]byte, len(b)+1)
+	ret[0] = headerPlain
+	copy(ret[1:], b)
+	return ret, nil
+}
+
+func (g *gzipCodec) Unmarshal(b []byte, v interface{}) error {
+	if len(b) == 0 {
+		return errEmptyPayload
+	}
+	switch b[0] {
+	case headerPlain:
+		return g.c.Unmarshal(b[1:], v)
+	case headerGzip:
+		r, err := gzip.NewReader(bytes.NewReader(b[1:]))
+		if err != nil {
+			return err
+		}
+		// reads one more byte for knowing it exceeds maxsize
+		data, err := io.ReadAll(io.LimitReader(r, g.maxsize+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) > g.maxsize {
+			return ErrGzipTooLarge
+		}
+		return g.c.Unmarshal(data, v)
+	}
+	return fmt.Errorf("redisgo: unknown codec header 0x%02x", b[0])
+}
@@ -0,0 +1,143 @@
+package redisgo_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+type item struct {
+	Name string
+	Tags []string
+}
+
+func TestCodec(t *testing.T) {
+	v := item{Name: "x", Tags: []string{"a", "b"}}
+	for _, c := range []redisgo.Codec{redisgo.JSONCodec, redisgo.GobCodec, redisgo.NewGzipCodec(redisgo.JSONCodec, 0)} {
+		b, err := c.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got item
+		if err := c.Unmarshal(b, &got); err != nil || !reflect.DeepEqual(got, v) {
+			t.Fatal(got, err)
+		}
+	}
+
+	b, _ := redisgo.RawCodec.Marshal("raw")
+	var s string
+	if err := redisgo.RawCodec.Unmarshal(b, &s); err != nil || s != "raw" {
+		t.Fatal(s, err)
+	}
+	if _, err := redisgo.RawCodec.Marshal(1); err == nil {
+		t.Fatal("expect err")
+	}
+}
+
+func TestGzipCodecFormat(t *testing.T) {
+	c := redisgo.NewGzipCodec(redisgo.RawCodec, 16)
+
+	// small values are not compressed
+	b, _ := c.Marshal("small")
+	if string(b) != "\x00small" {
+		t.Fatalf("%q", b)
+	}
+
+	// large values are gzip streams after the header
+	large := strings.Repeat("redisgo ", 100)
+	b, _ = c.Marshal(large)
+	if b[0] != 0x01 || len(b) >= len(large) {
+		t.Fatal(b[0], len(b))
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b[1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != large {
+		t.Fatal("not a gzip stream")
+	}
+	var s string
+	if err := c.Unmarshal(b, &s); err != nil || s != large {
+		t.Fatal(err)
+	}
+
+	// incompressible values are not compressed
+	random := make([]byte, 64)
+	for i := range random {
+		random[i] = byte(i * 97)
+	}
+	if b, _ = c.Marshal(random); b[0] != 0x00 {
+		t.Fatal(b[0])
+	}
+
+	for _, b := range []string{"", "\x02x", "\x01notgzip"} {
+		if err := c.Unmarshal([]byte(b), &s); err == nil {
+			t.Fatalf("%q expect err", b)
+		}
+	}
+}
+
+func TestGzipCodecMaxSize(t *testing.T) {
+	large := strings.Repeat("redisgo ", 100)
+	b, _ := redisgo.NewGzipCodec(redisgo.RawCodec, 16).Marshal(large)
+
+	var s string
+	c := redisgo.NewGzipCodec(redisgo.RawCodec, 16, redisgo.WithGzipMaxSize(int64(len(large))))
+	if err := c.Unmarshal(b, &s); err != nil || s != large {
+		t.Fatal(err)
+	}
+	c = redisgo.NewGzipCodec(redisgo.RawCodec, 16, redisgo.WithGzipMaxSize(int64(len(large)-1)))
+	if err := c.Unmarshal(b, &s); err != redisgo.ErrGzipTooLarge {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedClient(t *testing.T) {
+	s := redistest.NewServer()
+	defer s.Close()
+	s.SetTime(time.Unix(1000, 0))
+	pool := redisgo.NewPool(s.Dial)
+	ctx := context.Background()
+
+	c := redisgo.NewTypedClient[*item](pool, redisgo.NewGzipCodec(redisgo.GobCodec, 64))
+	if _, err := c.Get(ctx, "k"); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+	v := &item{Name: "x", Tags: []string{strings.Repeat("t", 100)}}
+	if err := c.Set(ctx, "k", v, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Get(ctx, "k")
+	if err != nil || !reflect.DeepEqual(got, v) {
+		t.Fatal(got, err)
+	}
+	s.FastForward(time.Second)
+	if _, err := c.Get(ctx, "k"); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+
+	raw := redisgo.NewTypedClient[[]byte](pool, redisgo.RawCodec)
+	if err := raw.Set(ctx, "r", []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := raw.Get(ctx, "r"); err != nil || string(b) != "v" {
+		t.Fatal(string(b), err)
+	}
+	if err := raw.Set(ctx, "r", []byte("x"), -time.Second); err == nil {
+		t.Fatal("expect err")
+	}
+	if b, err := raw.Get(ctx, "r"); err != nil || string(b) != "v" {
+		t.Fatal(string(b), err)
+	}
+	if pool.Active() != 1 {
+		t.Fatal(pool.Active())
+	}
+}
@@ -0,0 +1,511 @@
+package redisgo
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// collection is a key with the pool and the codec of a typed handle
+type collection struct {
+	p     *Pool
+	key   string
+	codec Codec
+}
+
+// do sends command with a conn of the pool, the conn is returned before decoding the reply.
+// Reply.Free() SHOULD be called when no longer used
+func (c *collection) do(ctx context.Context, cmd string, args ...interface{}) (*Reply, error) {
+	conn, err := c.p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.Do(cmd, args...)
+}
+
+func (c *collection) integer(ctx context.Context, cmd string, args ...interface{}) (int64, error) {
+	reply, err := c.do(ctx, cmd, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer reply.Free()
+	return reply.Integer()
+}
+
+func (c *collection) float(ctx context.Context, cmd string, args ...interface{}) (float64, error) {
+	reply, err := c.do(ctx, cmd, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer reply.Free()
+	return reply.Float64()
+}
+
+// encode returns args of key and values encoded by codec
+func encode[T any](c *collection, vv ...T) ([]interface{}, error) {
+	args := make([]interface{}, 0, 1+len(vv))
+	args = append(args, c.key)
+	for _, v := range vv {
+		b, err := c.codec.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, b)
+	}
+	return args, nil
+}
+
+// decode decodes a string reply with codec
+func decode[T any](c *collection, reply *Reply) (T, error) {
+	var v T
+	b, err := reply.Bytes()
+	if err == nil {
+		err = c.codec.Unmarshal(b, &v)
+	}
+	return v, err
+}
+
+// decodeall decodes elements of an array reply with codec
+func decodeall[T any](c *collection, reply *Reply) ([]T, error) {
+	aa, err := reply.Array()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]T, len(aa))
+	for i := range aa {
+		if ret[i], err = decode[T](c, &aa[i]); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// doone sends command and decodes the string reply with codec
+func doone[T any](ctx context.Context, c *collection, cmd string, args ...interface{}) (T, error) {
+	reply, err := c.do(ctx, cmd, args...)
+	if err != nil {
+		var v T
+		return v, err
+	}
+	defer reply.Free()
+	return decode[T](c, reply)
+}
+
+// doall sends command and decodes elements of the array reply with codec
+func doall[T any](ctx context.Context, c *collection, cmd string, args ...interface{}) ([]T, error) {
+	reply, err := c.do(ctx, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Free()
+	return decodeall[T](c, reply)
+}
+
+func typeof[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Z represents a member of a sorted set with its score
+type Z[T any] struct {
+	Member T
+	Score  float64
+}
+
+// RangeOpts represents options of SortedSet.RangeByScore
+type RangeOpts struct {
+	Offset int64 // LIMIT offset count if Count > 0
+	Count  int64
+
+	Rev bool // from high scores to low scores
+
+	ExclusiveMin bool
+	ExclusiveMax bool
+}
+
+// SortedSet is a handle of a sorted set whose members are T encoded by a Codec
+type SortedSet[T any] struct {
+	c collection
+}
+
+// NewSortedSet creates SortedSet of key, commands are sent with conns of p
+func NewSortedSet[T any](p *Pool, key string, codec Codec) *SortedSet[T] {
+	return &SortedSet[T]{c: collection{p: p, key: key, codec: codec}}
+}
+
+// Add adds member with score by ZADD, it returns false if the member exists and the score is updated
+func (z *SortedSet[T]) Add(ctx context.Context, score float64, member T) (bool, error) {
+	args, err := encode(&z.c, member)
+	if err != nil {
+		return false, err
+	}
+	args = append(args[:1], score, args[1])
+	n, err := z.c.integer(ctx, "ZADD", args...)
+	return n == 1, err
+}
+
+// Remove removes members by ZREM, and returns the number of members removed
+func (z *SortedSet[T]) Remove(ctx context.Context, members ...T) (int64, error) {
+	args, err := encode(&z.c, members...)
+	if err != nil {
+		return 0, err
+	}
+	return z.c.integer(ctx, "ZREM", args...)
+}
+
+// Score returns the score of member by ZSCORE, ErrNil is returned if it's not a member
+func (z *SortedSet[T]) Score(ctx context.Context, member T) (float64, error) {
+	args, err := encode(&z.c, member)
+	if err != nil {
+		return 0, err
+	}
+	return z.c.float(ctx, "ZSCORE", args...)
+}
+
+// IncrBy increments the score of member by delta with ZINCRBY, and returns the new score
+func (z *SortedSet[T]) IncrBy(ctx context.Context, member T, delta float64) (float64, error) {
+	args, err := encode(&z.c, member)
+	if err != nil {
+		return 0, err
+	}
+	args = append(args[:1], delta, args[1])
+	return z.c.float(ctx, "ZINCRBY", args...)
+}
+
+// Rank returns the 0-based rank of member from low scores to high scores by ZRANK,
+// ErrNil is returned if it's not a member
+func (z *SortedSet[T]) Rank(ctx context.Context, member T) (int64, error) {
+	return z.rank(ctx, "ZRANK", member)
+}
+
+// RevRank returns the 0-based rank of member from high scores to low scores by ZREVRANK,
+// ErrNil is returned if it's not a member
+func (z *SortedSet[T]) RevRank(ctx context.Context, member T) (int64, error) {
+	return z.rank(ctx, "ZREVRANK", member)
+}
+
+func (z *SortedSet[T]) rank(ctx context.Context, cmd string, member T) (int64, error) {
+	args, err := encode(&z.c, member)
+	if err != nil {
+		return 0, err
+	}
+	return z.c.integer(ctx, cmd, args...)
+}
+
+// Card returns the number of members by ZCARD
+func (z *SortedSet[T]) Card(ctx context.Context) (int64, error) {
+	return z.c.integer(ctx, "ZCARD", z.c.key)
+}
+
+// Range returns members ranked from start to stop by ZRANGE, negative ranks count from the end
+func (z *SortedSet[T]) Range(ctx context.Context, start, stop int64) ([]T, error) {
+	return doall[T](ctx, &z.c, "ZRANGE", z.c.key, start, stop)
+}
+
+// RangeByScore returns members with scores between min and max by ZRANGEBYSCORE or ZREVRANGEBYSCORE,
+// min and max can be math.Inf. opts can be nil.
+func (z *SortedSet[T]) RangeByScore(ctx context.Context, min, max float64, opts *RangeOpts) ([]Z[T], error) {
+	var o RangeOpts
+	if opts != nil {
+		o = *opts
+	}
+	cmd := "ZRANGEBYSCORE"
+	args := []interface{}{z.c.key, scorearg(min, o.ExclusiveMin), scorearg(max, o.ExclusiveMax)}
+	if o.Rev {
+		cmd = "ZREVRANGEBYSCORE"
+		args[1], args[2] = args[2], args[1]
+	}
+	args = append(args, "WITHSCORES")
+	if o.Count > 0 {
+		args = append(args, "LIMIT", o.Offset, o.Count)
+	}
+	reply, err := z.c.do(ctx, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Free()
+	aa, err := reply.Array()
+	if err != nil {
+		return nil, err
+	}
+	if len(aa)%2 != 0 {
+		return nil, &DecodeError{Type: reply.Type(), GoType: typeof[[]Z[T]]()}
+	}
+	ret := make([]Z[T], len(aa)/2)
+	for i := range ret {
+		if ret[i].Member, err = decode[T](&z.c, &aa[2*i]); err != nil {
+			return nil, err
+		}
+		if ret[i].Score, err = aa[2*i+1].Float64(); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// scorearg formats score for ZRANGEBYSCORE
+func scorearg(f float64, exclusive bool) string {
+	var s string
+	switch {
+	case math.IsInf(f, 1):
+		s = "+inf"
+	case math.IsInf(f, -1):
+		s = "-inf"
+	default:
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	if exclusive {
+		s = "(" + s
+	}
+	return s
+}
+
+// Hash is a handle of a hash whose fields are K and values are V.
+// Fields are encoded as command args and decoded like Reply.Unmarshal, values are encoded by a Codec.
+type Hash[K comparable, V any] struct {
+	c collection
+}
+
+// NewHash creates Hash of key, commands are sent with conns of p
+func NewHash[K comparable, V any](p *Pool, key string, codec Codec) *Hash[K, V] {
+	return &Hash[K, V]{c: collection{p: p, key: key, codec: codec}}
+}
+
+// Set sets field to v by HSET, it returns false if the field exists and the value is updated
+func (h *Hash[K, V]) Set(ctx context.Context, field K, v V) (bool, error) {
+	b, err := h.c.codec.Marshal(v)
+	if err != nil {
+		return false, err
+	}
+	n, err := h.c.integer(ctx, "HSET", h.c.key, field, b)
+	return n == 1, err
+}
+
+// SetAll sets fields of m by HSET
+func (h *Hash[K, V]) SetAll(ctx context.Context, m map[K]V) error {
+	if len(m) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, 1+2*len(m))
+	args = append(args, h.c.key)
+	for k, v := range m {
+		b, err := h.c.codec.Marshal(v)
+		if err != nil {
+			return err
+		}
+		args = append(args, k, b)
+	}
+	_, err := h.c.integer(ctx, "HSET", args...)
+	return err
+}
+
+// Get returns the value of field by HGET, ErrNil is returned if the field not exists
+func (h *Hash[K, V]) Get(ctx context.Context, field K) (V, error) {
+	return doone[V](ctx, &h.c, "HGET", h.c.key, field)
+}
+
+// GetAll returns all fields and values by HGETALL
+func (h *Hash[K, V]) GetAll(ctx context.Context) (map[K]V, error) {
+	reply, err := h.c.do(ctx, "HGETALL", h.c.key)
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Free()
+	aa, err := reply.Array()
+	if err != nil {
+		return nil, err
+	}
+	if len(aa)%2 != 0 {
+		return nil, &DecodeError{Type: reply.Type(), GoType: typeof[map[K]V]()}
+	}
+	ret := make(map[K]V, len(aa)/2)
+	for i := 0; i < len(aa); i += 2 {
+		var k K
+		if err := aa[i].Unmarshal(&k); err != nil {
+			return nil, err
+		}
+		if ret[k], err = decode[V](&h.c, &aa[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Del deletes fields by HDEL, and returns the number of fields deleted
+func (h *Hash[K, V]) Del(ctx context.Context, fields ...K) (int64, error) {
+	args := make([]interface{}, 0, 1+len(fields))
+	args = append(args, h.c.key)
+	for _, f := range fields {
+		args = append(args, f)
+	}
+	return h.c.integer(ctx, "HDEL", args...)
+}
+
+// Exists returns true if field exists by HEXISTS
+func (h *Hash[K, V]) Exists(ctx context.Context, field K) (bool, error) {
+	n, err := h.c.integer(ctx, "HEXISTS", h.c.key, field)
+	return n == 1, err
+}
+
+// Len returns the number of fields by HLEN
+func (h *Hash[K, V]) Len(ctx context.Context) (int64, error) {
+	return h.c.integer(ctx, "HLEN", h.c.key)
+}
+
+// Keys returns all fields by HKEYS
+func (h *Hash[K, V]) Keys(ctx context.Context) ([]K, error) {
+	reply, err := h.c.do(ctx, "HKEYS", h.c.key)
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Free()
+	var ret []K
+	err = reply.Unmarshal(&ret)
+	return ret, err
+}
+
+// IncrBy increments the value of field by delta with HINCRBY, and returns the new value.
+// It requires values stored as decimal integers, like int64 encoded by JSONCodec.
+func (h *Hash[K, V]) IncrBy(ctx context.Context, field K, delta int64) (int64, error) {
+	return h.c.integer(ctx, "HINCRBY", h.c.key, field, delta)
+}
+
+// List is a handle of a list whose elements are T encoded by a Codec
+type List[T any] struct {
+	c collection
+}
+
+// NewList creates List of key, commands are sent with conns of p
+func NewList[T any](p *Pool, key string, codec Codec) *List[T] {
+	return &List[T]{c: collection{p: p, key: key, codec: codec}}
+}
+
+// LPush inserts vv at the head by LPUSH, and returns the length of the list
+func (l *List[T]) LPush(ctx context.Context, vv ...T) (int64, error) {
+	args, err := encode(&l.c, vv...)
+	if err != nil {
+		return 0, err
+	}
+	return l.c.integer(ctx, "LPUSH", args...)
+}
+
+// RPush inserts vv at the tail by RPUSH, and returns the length of the list
+func (l *List[T]) RPush(ctx context.Context, vv ...T) (int64, error) {
+	args, err := encode(&l.c, vv...)
+	if err != nil {
+		return 0, err
+	}
+	return l.c.integer(ctx, "RPUSH", args...)
+}
+
+// LPop removes and returns the head by LPOP, ErrNil is returned if the list is empty
+func (l *List[T]) LPop(ctx context.Context) (T, error) {
+	return doone[T](ctx, &l.c, "LPOP", l.c.key)
+}
+
+// RPop removes and returns the tail by RPOP, ErrNil is returned if the list is empty
+func (l *List[T]) RPop(ctx context.Context) (T, error) {
+	return doone[T](ctx, &l.c, "RPOP", l.c.key)
+}
+
+// Index returns the element at index by LINDEX, ErrNil is returned if out of range
+func (l *List[T]) Index(ctx context.Context, index int64) (T, error) {
+	return doone[T](ctx, &l.c, "LINDEX", l.c.key, index)
+}
+
+// Set sets the element at index by LSET
+func (l *List[T]) Set(ctx context.Context, index int64, v T) error {
+	args, err := encode(&l.c, v)
+	if err != nil {
+		return err
+	}
+	reply, err := l.c.do(ctx, "LSET", args[0], index, args[1])
+	if err != nil {
+		return err
+	}
+	defer reply.Free()
+	return reply.Err()
+}
+
+// Range returns elements from start to stop by LRANGE, negative indexes count from the end
+func (l *List[T]) Range(ctx context.Context, start, stop int64) ([]T, error) {
+	return doall[T](ctx, &l.c, "LRANGE", l.c.key, start, stop)
+}
+
+// Remove removes count occurrences of v by LREM, and returns the number of elements removed
+func (l *List[T]) Remove(ctx context.Context, count int64, v T) (int64, error) {
+	args, err := encode(&l.c, v)
+	if err != nil {
+		return 0, err
+	}
+	return l.c.integer(ctx, "LREM", args[0], count, args[1])
+}
+
+// Trim keeps elements from start to stop by LTRIM
+func (l *List[T]) Trim(ctx context.Context, start, stop int64) error {
+	reply, err := l.c.do(ctx, "LTRIM", l.c.key, start, stop)
+	if err != nil {
+		return err
+	}
+	defer reply.Free()
+	return reply.Err()
+}
+
+// Len returns the length of the list by LLEN
+func (l *List[T]) Len(ctx context.Context) (int64, error) {
+	return l.c.integer(ctx, "LLEN", l.c.key)
+}
+
+// Set is a handle of a set whose members are T encoded by a Codec
+type Set[T any] struct {
+	c collection
+}
+
+// NewSet creates Set of key, commands are sent with conns of p
+func NewSet[T any](p *Pool, key string, codec Codec) *Set[T] {
+	return &Set[T]{c: collection{p: p, key: key, codec: codec}}
+}
+
+// Add adds members by SADD, and returns the number of members added
+func (s *Set[T]) Add(ctx context.Context, members ...T) (int64, error) {
+	args, err := encode(&s.c, members...)
+	if err != nil {
+		return 0, err
+	}
+	return s.c.integer(ctx, "SADD", args...)
+}
+
+// Remove removes members by SREM, and returns the number of members removed
+func (s *Set[T]) Remove(ctx context.Context, members ...T) (int64, error) {
+	args, err := encode(&s.c, members...)
+	if err != nil {
+		return 0, err
+	}
+	return s.c.integer(ctx, "SREM", args...)
+}
+
+// IsMember returns true if v is a member by SISMEMBER
+func (s *Set[T]) IsMember(ctx context.Context, v T) (bool, error) {
+	args, err := encode(&s.c, v)
+	if err != nil {
+		return false, err
+	}
+	n, err := s.c.integer(ctx, "SISMEMBER", args...)
+	return n == 1, err
+}
+
+// Members returns all members by SMEMBERS
+func (s *Set[T]) Members(ctx context.Context) ([]T, error) {
+	return doall[T](ctx, &s.c, "SMEMBERS", s.c.key)
+}
+
+// Pop removes and returns a random member by SPOP, ErrNil is returned if the set is empty
+func (s *Set[T]) Pop(ctx context.Context) (T, error) {
+	return doone[T](ctx, &s.c, "SPOP", s.c.key)
+}
+
+// Card returns the number of members by SCARD
+func (s *Set[T]) Card(ctx context.Context) (int64, error) {
+	return s.c.integer(ctx, "SCARD", s.c.key)
+}
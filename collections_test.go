@@ -0,0 +1,177 @@
+package redisgo_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+func TestSortedSet(t *testing.T) {
+	s := redistest.NewServer()
+	defer s.Close()
+	pool := redisgo.NewPool(s.Dial)
+	ctx := context.Background()
+
+	z := redisgo.NewSortedSet[item](pool, "z", redisgo.JSONCodec)
+	a, b, c := item{Name: "a"}, item{Name: "b"}, item{Name: "c"}
+	for i, m := range []item{a, b, c} {
+		if added, err := z.Add(ctx, float64(i+1), m); err != nil || !added {
+			t.Fatal(added, err)
+		}
+	}
+	if added, _ := z.Add(ctx, 1, a); added {
+		t.Fatal("added twice")
+	}
+	if v, err := z.IncrBy(ctx, a, 0.5); err != nil || v != 1.5 {
+		t.Fatal(v, err)
+	}
+	if v, err := z.Score(ctx, a); err != nil || v != 1.5 {
+		t.Fatal(v, err)
+	}
+	if _, err := z.Score(ctx, item{Name: "x"}); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+	if r, err := z.Rank(ctx, c); err != nil || r != 2 {
+		t.Fatal(r, err)
+	}
+	if r, err := z.RevRank(ctx, c); err != nil || r != 0 {
+		t.Fatal(r, err)
+	}
+	if _, err := z.Rank(ctx, item{Name: "x"}); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+	if v, err := z.Range(ctx, 0, -1); err != nil || !reflect.DeepEqual(v, []item{a, b, c}) {
+		t.Fatal(v, err)
+	}
+	v, err := z.RangeByScore(ctx, 1.5, math.Inf(1), &redisgo.RangeOpts{ExclusiveMin: true})
+	if err != nil || !reflect.DeepEqual(v, []redisgo.Z[item]{{b, 2}, {c, 3}}) {
+		t.Fatal(v, err)
+	}
+	v, err = z.RangeByScore(ctx, math.Inf(-1), math.Inf(1), &redisgo.RangeOpts{Rev: true, Offset: 1, Count: 1})
+	if err != nil || !reflect.DeepEqual(v, []redisgo.Z[item]{{b, 2}}) {
+		t.Fatal(v, err)
+	}
+	if n, err := z.Remove(ctx, a, item{Name: "x"}); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	if n, err := z.Card(ctx); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	s := redistest.NewServer()
+	defer s.Close()
+	pool := redisgo.NewPool(s.Dial)
+	ctx := context.Background()
+
+	h := redisgo.NewHash[int, item](pool, "h", redisgo.GobCodec)
+	if created, err := h.Set(ctx, 1, item{Name: "a"}); err != nil || !created {
+		t.Fatal(created, err)
+	}
+	if err := h.SetAll(ctx, map[int]item{2: {Name: "b"}, 3: {Name: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Get(ctx, 2); err != nil || v.Name != "b" {
+		t.Fatal(v, err)
+	}
+	if _, err := h.Get(ctx, 4); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+	if m, err := h.GetAll(ctx); err != nil || len(m) != 3 || m[3].Name != "c" {
+		t.Fatal(m, err)
+	}
+	keys, err := h.Keys(ctx)
+	sort.Ints(keys)
+	if err != nil || !reflect.DeepEqual(keys, []int{1, 2, 3}) {
+		t.Fatal(keys, err)
+	}
+	if ok, err := h.Exists(ctx, 3); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	if n, err := h.Del(ctx, 1, 4); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	if n, err := h.Len(ctx); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+
+	counters := redisgo.NewHash[string, int64](pool, "counters", redisgo.JSONCodec)
+	counters.Set(ctx, "x", 1)
+	if v, err := counters.IncrBy(ctx, "x", 2); err != nil || v != 3 {
+		t.Fatal(v, err)
+	}
+	if v, err := counters.Get(ctx, "x"); err != nil || v != 3 {
+		t.Fatal(v, err)
+	}
+}
+
+func TestListAndSet(t *testing.T) {
+	s := redistest.NewServer()
+	defer s.Close()
+	pool := redisgo.NewPool(s.Dial)
+	ctx := context.Background()
+
+	l := redisgo.NewList[string](pool, "l", redisgo.RawCodec)
+	if n, err := l.RPush(ctx, "b", "c"); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	l.LPush(ctx, "a")
+	l.RPush(ctx, "x", "d")
+	if err := l.Set(ctx, 3, "y"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := l.Remove(ctx, 0, "y"); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	if v, err := l.Range(ctx, 0, -1); err != nil || !reflect.DeepEqual(v, []string{"a", "b", "c", "d"}) {
+		t.Fatal(v, err)
+	}
+	if v, err := l.Index(ctx, -1); err != nil || v != "d" {
+		t.Fatal(v, err)
+	}
+	if err := l.Trim(ctx, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := l.LPop(ctx); err != nil || v != "a" {
+		t.Fatal(v, err)
+	}
+	if v, err := l.RPop(ctx); err != nil || v != "c" {
+		t.Fatal(v, err)
+	}
+	if n, err := l.Len(ctx); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	l.LPop(ctx)
+	if _, err := l.LPop(ctx); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+
+	set := redisgo.NewSet[[]int](pool, "s", redisgo.JSONCodec)
+	if n, err := set.Add(ctx, []int{1}, []int{2, 3}, []int{1}); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	if ok, err := set.IsMember(ctx, []int{2, 3}); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	if n, err := set.Remove(ctx, []int{1}); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	if v, err := set.Members(ctx); err != nil || !reflect.DeepEqual(v, [][]int{{2, 3}}) {
+		t.Fatal(v, err)
+	}
+	if n, err := set.Card(ctx); err != nil || n != 1 {
+		t.Fatal(n, err)
+	}
+	if v, err := set.Pop(ctx); err != nil || !reflect.DeepEqual(v, []int{2, 3}) {
+		t.Fatal(v, err)
+	}
+	if _, err := set.Pop(ctx); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+}
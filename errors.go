@@ -1,20 +1,33 @@
 package redisgo
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/xiaost/redisgo/resp"
+)
 
 var (
-	ErrNil       = errors.New("redisgo: nil")
+	ErrNil       = resp.ErrNil
 	ErrMaxActive = errors.New("redisgo: max active connection exceeded")
 
-	errProtocol       = errors.New("redisgo: protocol err")
-	errTypeMismatch   = errors.New("redisgo: type mismatch")
-	errClosed         = errors.New("redisgo: closed")
-	errInvalidArgType = errors.New("redisgo: invalid args type")
+	// ErrDialBackoff is returned by Pool.Get without dialing after dial failures, see WithDialBackoff
+	ErrDialBackoff = errors.New("redisgo: dial backoff after failures")
+
+	// ErrInvalidArgType is returned if any arg of a command is not supported
+	ErrInvalidArgType = resp.ErrInvalidArgType
+
+	errClosed     = errors.New("redisgo: closed")
+	errDrainLimit = errors.New("redisgo: pending replies exceed the drain limit")
 )
 
+// ProtocolError represents an invalid or unacceptable reply from redis.
+// the Conn is closed after it returned
+type ProtocolError = resp.ProtocolError
+
 // RedisErr represents a server side err
 // https://redis.io/topics/protocol#resp-errors
-type RedisErr []byte
+type RedisErr = resp.RedisErr
 
-// RedisErr implements error interface
-func (err RedisErr) Error() string { return ss(err) }
+// DecodeError represents a failure of converting a reply to a Go value,
+// it names the element and the RESP type found, see Reply.Unmarshal
+type DecodeError = resp.DecodeError
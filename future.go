@@ -0,0 +1,310 @@
+package redisgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xiaost/redisgo/resp"
+)
+
+// ErrNotSent is returned by futures which are not written before the connection broken,
+// the commands are not executed by redis, so it's safe to retry them.
+var ErrNotSent = errors.New("redisgo: command not sent")
+
+var (
+	errPending = errors.New("redisgo: async commands with replies pending")
+	errAsync   = errors.New("redisgo: Send and Recv are not allowed after DoAsync")
+)
+
+// states of Future
+const (
+	futureWaiting int32 = iota
+	futureDone
+	futureCanceled
+)
+
+// Future represents the reply of a command sent asynchronously
+type Future struct {
+	ctx   context.Context // commands are not written if ctx is done
+	cmd   string
+	args  []interface{}
+	fn    func(reply *Reply, err error)
+	reply *Reply
+	err   error
+	state int32
+	done  chan struct{}
+}
+
+func newFuture(ctx context.Context, fn func(*Reply, error), cmd string, args []interface{}) *Future {
+	return &Future{ctx: ctx, cmd: cmd, args: args, fn: fn, reply: resp.NewReply(), done: make(chan struct{})}
+}
+
+// finish completes f with err, the reply is freed if the waiter is gone or after the callback
+func (f *Future) finish(err error) {
+	f.err = err
+	if !atomic.CompareAndSwapInt32(&f.state, futureWaiting, futureDone) {
+		f.reply.Free()
+		return
+	}
+	close(f.done)
+	if f.fn != nil {
+		if err != nil {
+			f.fn(nil, err)
+		} else {
+			f.fn(f.reply, nil)
+		}
+		f.reply.Free()
+	}
+}
+
+func (f *Future) canceled() bool {
+	return atomic.LoadInt32(&f.state) == futureCanceled
+}
+
+// Done returns a channel which is closed when the reply is received or the command failed
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the reply of the command.
+// If ctx is done before that, the command is canceled and ctx.Err() is returned,
+// the reply will be discarded without affecting other commands.
+// Wait must be called at most once, and Reply.Free() SHOULD be called when no longer used
+func (f *Future) Wait(ctx context.Context) (*Reply, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		if atomic.CompareAndSwapInt32(&f.state, futureWaiting, futureCanceled) {
+			return nil, ctx.Err()
+		}
+		<-f.done
+	}
+	if f.err != nil {
+		f.reply.Free()
+		return nil, f.err
+	}
+	return f.reply, nil
+}
+
+// pipeline writes commands of futures in batches by a writer goroutine,
+// and reads replies of them in FIFO order by a reader goroutine.
+type pipeline struct {
+	c *Conn
+
+	reqs    chan *Future // waiting to be written
+	pending chan *Future // written, waiting for replies
+
+	once sync.Once
+	err  error
+	done chan struct{}
+}
+
+func newPipeline(c *Conn, maxpending int) *pipeline {
+	p := &pipeline{
+		c:       c,
+		reqs:    make(chan *Future, maxpending),
+		pending: make(chan *Future, maxpending),
+		done:    make(chan struct{}),
+	}
+	go p.writeloop()
+	go p.readloop()
+	return p
+}
+
+// send queues f, it blocks if too many futures are waiting to be written until f.ctx is done
+func (p *pipeline) send(f *Future) {
+	select {
+	case p.reqs <- f:
+	case <-p.done:
+		f.finish(ErrNotSent)
+		return
+	case <-f.ctx.Done():
+		f.finish(f.ctx.Err())
+		return
+	}
+	if p.isclosed() {
+		p.drain(p.reqs, ErrNotSent) // in case of the writer exited before f queued
+	}
+}
+
+func (p *pipeline) isclosed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the fatal err which closes the pipeline, or nil if it's alive
+func (p *pipeline) Err() error {
+	if p.isclosed() {
+		return p.err
+	}
+	return nil
+}
+
+// close closes p with errClosed, it returns errClosed if p is already closed
+func (p *pipeline) close() error {
+	if p.isclosed() {
+		return errClosed
+	}
+	p.fail(errClosed)
+	return nil
+}
+
+func (p *pipeline) fail(err error) {
+	p.once.Do(func() {
+		p.err = err
+		close(p.done)
+		p.c.conn.Close()
+	})
+}
+
+// drain fails all futures left in ch after p is closed
+func (p *pipeline) drain(ch chan *Future, err error) {
+	for {
+		select {
+		case f := <-ch:
+			f.finish(err)
+		default:
+			return
+		}
+	}
+}
+
+func (p *pipeline) writeloop() {
+	defer func() {
+		p.drain(p.reqs, ErrNotSent)
+		p.drain(p.pending, p.err)
+	}()
+	c := p.c
+	for {
+		var f *Future
+		select {
+		case f = <-p.reqs:
+		case <-p.done:
+			return
+		}
+		if c.wtimeout > 0 {
+			c.conn.SetWriteDeadline(time.Now().Add(c.wtimeout))
+		}
+		for f != nil {
+			if !p.write(f) {
+				return
+			}
+			select {
+			case f = <-p.reqs:
+			default:
+				f = nil
+			}
+		}
+		if err := c.enc.Flush(); err != nil {
+			p.fail(err)
+			return
+		}
+	}
+}
+
+// write writes f and queues it to p.pending, it returns false if p is closed
+func (p *pipeline) write(f *Future) bool {
+	if p.isclosed() {
+		f.finish(ErrNotSent)
+		return false
+	}
+	if f.canceled() {
+		f.reply.Free()
+		return true
+	}
+	if err := f.ctx.Err(); err != nil {
+		f.finish(err)
+		return true
+	}
+	err := p.c.enc.WriteCommand(f.cmd, f.args...)
+	if errors.Is(err, ErrInvalidArgType) { // nothing written
+		f.finish(err)
+		return true
+	}
+	if err != nil {
+		p.fail(err)
+		f.finish(p.err)
+		return false
+	}
+	f.args = nil
+	select {
+	case p.pending <- f:
+		return true
+	default:
+	}
+	// too many futures waiting for replies, flush before blocking
+	if err = p.c.enc.Flush(); err != nil {
+		p.fail(err)
+		f.finish(p.err)
+		return false
+	}
+	select {
+	case p.pending <- f:
+		return true
+	case <-p.done:
+		f.finish(p.err)
+		return false
+	}
+}
+
+func (p *pipeline) readloop() {
+	defer func() {
+		p.drain(p.pending, p.err)
+	}()
+	c := p.c
+	for {
+		var f *Future
+		select {
+		case f = <-p.pending:
+		case <-p.done:
+			return
+		}
+		if c.rtimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.rtimeout))
+		}
+		if err := c.dec.Decode(f.reply); err != nil {
+			p.fail(err)
+			f.finish(p.err)
+			return
+		}
+		f.finish(nil)
+	}
+}
+
+// DoAsync sends command to redis by the writer goroutine of the Conn, and returns the Future of the reply.
+// The writer and reader goroutines of the Conn are started on the first call,
+// after that only DoAsync, DoAsyncFunc, Do and Close of the Conn should be used.
+// It blocks if there are too many commands pending, see WithMaxPending.
+func (c *Conn) DoAsync(cmd string, args ...interface{}) *Future {
+	return c.doasync(context.Background(), nil, cmd, args)
+}
+
+// DoAsyncFunc is the callback form of DoAsync, fn is called with the reply or err of the command.
+// fn is called in a goroutine of the Conn, it must not block, and the reply is freed after fn returns.
+func (c *Conn) DoAsyncFunc(fn func(reply *Reply, err error), cmd string, args ...interface{}) {
+	c.doasync(context.Background(), fn, cmd, args)
+}
+
+func (c *Conn) doasync(ctx context.Context, fn func(*Reply, error), cmd string, args []interface{}) *Future {
+	f := newFuture(ctx, fn, cmd, args)
+	if c.p == nil {
+		if err := c.Err(); err != nil {
+			f.finish(err)
+			return f
+		}
+		if c.pd != 0 {
+			f.finish(errPending)
+			return f
+		}
+		c.p = newPipeline(c, c.maxpending)
+	}
+	c.p.send(f)
+	return f
+}
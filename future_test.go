@@ -0,0 +1,132 @@
+package redisgo_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+func TestDoAsync(t *testing.T) {
+	s := redistest.NewUnstartedServer()
+	defer s.Close()
+	conn := redisgo.NewConn(s.Pipe())
+	defer conn.Close()
+
+	const n = 100
+	ctx := context.Background()
+	ff := make([]*redisgo.Future, n)
+	for i := range ff {
+		ff[i] = conn.DoAsync("INCR", "k")
+	}
+	for i, f := range ff {
+		reply, err := f.Wait(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := reply.Integer(); v != int64(i+1) {
+			t.Fatal(i, v)
+		}
+		reply.Free()
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var vals []string
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		conn.DoAsyncFunc(func(reply *redisgo.Reply, err error) {
+			defer wg.Done()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			b, _ := reply.Bytes()
+			mu.Lock()
+			vals = append(vals, string(b))
+			mu.Unlock()
+		}, "ECHO", i)
+	}
+	wg.Wait()
+	for i, v := range vals {
+		if v != strconv.Itoa(i) {
+			t.Fatal(i, v)
+		}
+	}
+
+	// Do still works, Send and Recv are not allowed
+	if b, err := conn.DoBytes("ECHO", "x"); err != nil || string(b) != "x" {
+		t.Fatal(b, err)
+	}
+	if err := conn.Send("PING"); err == nil {
+		t.Fatal("expect err")
+	}
+
+	// canceled futures do not affect others
+	f0 := conn.DoAsync("ECHO", "0")
+	f1 := conn.DoAsync("ECHO", "1")
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := f0.Wait(canceled); err != context.Canceled {
+		t.Fatal(err)
+	}
+	reply, err := f1.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := reply.Bytes(); string(b) != "1" {
+		t.Fatal(string(b))
+	}
+	reply.Free()
+
+	// pending replies of Send
+	conn = redisgo.NewConn(s.Pipe())
+	defer conn.Close()
+	conn.Send("PING")
+	if _, err := conn.DoAsync("PING").Wait(ctx); err == nil {
+		t.Fatal("expect err")
+	}
+}
+
+func TestDoAsyncBroken(t *testing.T) {
+	mock := redistest.NewMock()
+	defer mock.Close()
+	neterr := errors.New("connection reset")
+	mock.Expect("GET", "a").Return("1")
+	mock.Expect("GET", "b").Return("2").Delay(20 * time.Millisecond).NetError(neterr)
+	mock.ExpectFunc("GET", func(args [][]byte) bool { return true }).Times(100)
+	conn := redisgo.NewConn(mock.Conn())
+	defer conn.Close()
+
+	ctx := context.Background()
+	fa := conn.DoAsync("GET", "a")
+	fb := conn.DoAsync("GET", "b")
+	var ff []*redisgo.Future
+	for i := 0; i < 100; i++ {
+		ff = append(ff, conn.DoAsync("GET", i))
+	}
+	if reply, err := fa.Wait(ctx); err != nil {
+		t.Fatal(err)
+	} else {
+		reply.Free()
+	}
+	if _, err := fb.Wait(ctx); err != neterr {
+		t.Fatal(err)
+	}
+	for _, f := range ff {
+		if _, err := f.Wait(ctx); err != neterr && err != redisgo.ErrNotSent {
+			t.Fatal(err)
+		}
+	}
+	if _, err := conn.DoAsync("GET", "c").Wait(ctx); err != redisgo.ErrNotSent {
+		t.Fatal(err)
+	}
+	if conn.Err() != neterr {
+		t.Fatal(conn.Err())
+	}
+}
@@ -1 +1,3 @@
 module github.com/xiaost/redisgo
+
+go 1.23
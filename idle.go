@@ -0,0 +1,159 @@
+package redisgo
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// procPin pins the goroutine to its P (the processor of the runtime) and returns the id of the P,
+// it's used by sync.Pool for the same purpose.
+//
+//go:linkname procPin runtime.procPin
+func procPin() int
+
+//go:linkname procUnpin runtime.procUnpin
+func procUnpin()
+
+// idlestore stores idle conns of a pool in shards per P for reducing contention under high core counts.
+// put and get use the shard of the current P first, and other shards are tried only if it's full or empty,
+// so that goroutines running on different cores rarely touch the same cache lines.
+// The number of idle conns is limited by max, which is split over shards.
+type idlestore struct {
+	shards []idleshard
+	fifo   bool
+}
+
+type idleshardfields struct {
+	mu    sync.Mutex
+	conns []*PoolConn // ring buffer with the capacity of the shard
+	start int         // index of the least recently put conn
+	n     int64       // number of conns, it's read without mu for skipping shards
+}
+
+type idleshard struct {
+	idleshardfields
+
+	_ [64 - unsafe.Sizeof(idleshardfields{})%64]byte // pads to cache lines for avoiding false sharing
+}
+
+func (s *idlestore) init(max int, order IdleOrder) {
+	n := min(runtime.GOMAXPROCS(0), max)
+	if n < 0 {
+		n = 0
+	}
+	s.shards = make([]idleshard, n)
+	for i := range s.shards {
+		sz := max / n
+		if i < max%n {
+			sz++
+		}
+		s.shards[i].conns = make([]*PoolConn, sz)
+	}
+	s.fifo = order == FIFO
+}
+
+func (s *idlestore) len() int {
+	n := 0
+	for i := range s.shards {
+		n += int(atomic.LoadInt64(&s.shards[i].n))
+	}
+	return n
+}
+
+// local returns the index of the shard of the current P
+func (s *idlestore) local() int {
+	i := procPin()
+	procUnpin()
+	if i >= len(s.shards) { // GOMAXPROCS > max or it's changed
+		i %= len(s.shards)
+	}
+	return i
+}
+
+// put returns false if the store is full
+func (s *idlestore) put(c *PoolConn) bool {
+	if len(s.shards) == 0 {
+		return false
+	}
+	i := s.local()
+	if s.shards[i].push(c) {
+		return true
+	}
+	for j := 1; j < len(s.shards); j++ {
+		if i++; i == len(s.shards) {
+			i = 0
+		}
+		sh := &s.shards[i]
+		if atomic.LoadInt64(&sh.n) < int64(len(sh.conns)) && sh.push(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns nil if no idle conns
+func (s *idlestore) get() *PoolConn {
+	if len(s.shards) == 0 {
+		return nil
+	}
+	i := s.local()
+	if c := s.shards[i].pop(s.fifo); c != nil {
+		return c
+	}
+	for j := 1; j < len(s.shards); j++ {
+		if i++; i == len(s.shards) {
+			i = 0
+		}
+		sh := &s.shards[i]
+		if atomic.LoadInt64(&sh.n) > 0 {
+			if c := sh.pop(s.fifo); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// push returns false if the shard is full
+func (sh *idleshard) push(c *PoolConn) bool {
+	sh.mu.Lock()
+	n := int(sh.n)
+	if n == len(sh.conns) {
+		sh.mu.Unlock()
+		return false
+	}
+	i := sh.start + n
+	if i >= len(sh.conns) {
+		i -= len(sh.conns)
+	}
+	sh.conns[i] = c
+	atomic.StoreInt64(&sh.n, int64(n+1))
+	sh.mu.Unlock()
+	return true
+}
+
+// pop removes the least recently put conn if fifo, or the most recently put one,
+// it returns nil if the shard is empty
+func (sh *idleshard) pop(fifo bool) *PoolConn {
+	sh.mu.Lock()
+	n := int(sh.n)
+	if n == 0 {
+		sh.mu.Unlock()
+		return nil
+	}
+	i := sh.start
+	if fifo {
+		if sh.start++; sh.start == len(sh.conns) {
+			sh.start = 0
+		}
+	} else if i += n - 1; i >= len(sh.conns) {
+		i -= len(sh.conns)
+	}
+	c := sh.conns[i]
+	sh.conns[i] = nil
+	atomic.StoreInt64(&sh.n, int64(n-1))
+	sh.mu.Unlock()
+	return c
+}
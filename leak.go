@@ -0,0 +1,104 @@
+package redisgo
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Leak represents a PoolConn borrowed and not returned yet
+type Leak struct {
+	BorrowedAt time.Time
+	Stack      string // stack trace of the borrower
+}
+
+// borrow records the borrower of a PoolConn in debug mode
+type borrow struct {
+	t   time.Time
+	pcs []uintptr
+}
+
+func (b *borrow) stack() string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(b.pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+// WithDebug enables debug mode of the pool if b is true, default: false.
+// In debug mode, the stack trace and the time of borrowing are recorded for every PoolConn,
+// see Pool.Leaks. PoolConns garbage collected without Close are reported to WithWarnFunc.
+func WithDebug(b bool) PoolOption {
+	return func(p *Pool) {
+		p.debug = b
+	}
+}
+
+// track records the borrower of c, it must be called by Pool.Get directly
+func (p *Pool) track(c *PoolConn) {
+	if !p.debug {
+		return
+	}
+	b := &borrow{t: p.nowfunc(), pcs: make([]uintptr, 32)}
+	b.pcs = b.pcs[:runtime.Callers(3, b.pcs)]
+	p.mu.Lock()
+	p.borrows[b] = struct{}{}
+	p.mu.Unlock()
+	c.b = b
+}
+
+// untrack removes the borrower of c
+func (p *Pool) untrack(c *PoolConn) {
+	if c.b == nil {
+		return
+	}
+	p.mu.Lock()
+	delete(p.borrows, c.b)
+	p.mu.Unlock()
+	c.b = nil
+}
+
+func finalizePoolConn(c *PoolConn) {
+	p := c.p
+	b := c.b
+	if p == nil || b == nil { // returned
+		return
+	}
+	p.untrack(c)
+	p.warn(fmt.Sprintf("redisgo: PoolConn borrowed at %s is garbage collected without Close, borrowed by:\n%s",
+		b.t.Format(time.RFC3339), b.stack()))
+	if p.breaker != nil {
+		p.breaker.cancel(c.bgen)
+	}
+	atomic.AddInt64(&p.active, -1)
+	c.Conn.Close()
+}
+
+// Leaks returns PoolConns borrowed for more than olderThan and not returned yet, the oldest first.
+// It only works in debug mode, see WithDebug.
+func (p *Pool) Leaks(olderThan time.Duration) []Leak {
+	now := p.nowfunc()
+	var bb []*borrow
+	p.mu.Lock()
+	for b := range p.borrows {
+		if now.Sub(b.t) > olderThan {
+			bb = append(bb, b)
+		}
+	}
+	p.mu.Unlock()
+	sort.Slice(bb, func(i, j int) bool { return bb[i].t.Before(bb[j].t) })
+	ret := make([]Leak, len(bb))
+	for i, b := range bb {
+		ret[i] = Leak{BorrowedAt: b.t, Stack: b.stack()}
+	}
+	return ret
+}
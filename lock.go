@@ -0,0 +1,356 @@
+package redisgo
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLockNotAcquired is returned by Locker.Acquire if the lock is held by others until ctx is done
+	ErrLockNotAcquired = errors.New("redisgo: lock not acquired")
+
+	// ErrLockNotHeld is returned by Lock.Extend and Lock.Release if the lock expired or was taken by others
+	ErrLockNotHeld = errors.New("redisgo: lock not held")
+
+	// errLockTTL is returned by Lock.Extend for ttl < 1ms, which would be sent as PX 0 and delete the lock
+	errLockTTL = errors.New("redisgo: lock ttl less than 1ms")
+)
+
+var (
+	// releaseScript deletes the key only if it's still set to the token
+	releaseScript = NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	// extendScript sets the ttl of the key only if it's still set to the token
+	extendScript = NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// fenceScript is SET NX PX with the fencing counter KEYS[2] incremented in the same step
+	fenceScript = NewScript(2, `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+)
+
+// Locker acquires distributed locks of keys with SET key token NX PX ttl.
+//
+// A Locker created by NewRedlock acquires a lock on the majority of independent redis instances,
+// like the Redlock algorithm described in https://redis.io/docs/latest/develop/use/patterns/distributed-locks/
+type Locker struct {
+	pools  []*Pool
+	quorum int
+	ttl    time.Duration
+
+	retrymin time.Duration
+	retrymax time.Duration
+	renew    time.Duration
+	fencing  bool
+}
+
+// LockerOption represents options of Locker
+type LockerOption func(l *Locker)
+
+// WithLockRetry sets the backoff between attempts of Locker.Acquire,
+// it doubles from min to max with jitter, default: 10ms, 500ms.
+// min is at least 1ms, and max is at least min.
+func WithLockRetry(min, max time.Duration) LockerOption {
+	return func(l *Locker) {
+		l.retrymin = min
+		l.retrymax = max
+	}
+}
+
+// WithLockRenewal extends acquired locks to ttl again every interval in a goroutine,
+// Lock.Lost is closed if it fails before the lock expires. default: 0, no renewal.
+func WithLockRenewal(interval time.Duration) LockerOption {
+	return func(l *Locker) {
+		l.renew = interval
+	}
+}
+
+// WithLockFencing makes Locker increment the counter key "<key>:fence" when acquiring a lock,
+// which is returned by Lock.Fence and can be checked by storages against stale holders.
+// The key of a lock should contain a hash tag like "{order:1}" for redis cluster.
+func WithLockFencing() LockerOption {
+	return func(l *Locker) {
+		l.fencing = true
+	}
+}
+
+// NewLocker creates Locker with p, locks expire after ttl unless extended.
+// It panics if ttl < 1ms.
+func NewLocker(p *Pool, ttl time.Duration, ops ...LockerOption) *Locker {
+	return NewRedlock([]*Pool{p}, ttl, ops...)
+}
+
+// NewRedlock creates Locker with pools of independent redis instances,
+// a lock is acquired only if it's set on the majority of them within ttl.
+// It panics if ttl < 1ms.
+func NewRedlock(pools []*Pool, ttl time.Duration, ops ...LockerOption) *Locker {
+	if ttl < time.Millisecond {
+		panic("redisgo: NewRedlock with ttl less than 1ms")
+	}
+	l := &Locker{
+		pools:    pools,
+		quorum:   len(pools)/2 + 1,
+		ttl:      ttl,
+		retrymin: 10 * time.Millisecond,
+		retrymax: 500 * time.Millisecond,
+	}
+	for _, op := range ops {
+		op(l)
+	}
+	if l.retrymin <= 0 {
+		l.retrymin = time.Millisecond
+	}
+	if l.retrymax < l.retrymin {
+		l.retrymax = l.retrymin
+	}
+	return l
+}
+
+// Lock represents an acquired lock
+type Lock struct {
+	l     *Locker
+	key   string
+	token string
+	fence int64
+
+	mu    sync.Mutex
+	until time.Time
+	lost  chan struct{}
+	stop  chan struct{}
+	once  sync.Once
+}
+
+// Acquire acquires the lock of key, it retries with backoff until ctx is done
+func (l *Locker) Acquire(ctx context.Context, key string) (*Lock, error) {
+	delay := l.retrymin
+	for {
+		lk, err := l.TryAcquire(ctx, key)
+		if err != ErrLockNotAcquired {
+			return lk, err
+		}
+		d := delay/2 + rand.N(delay/2+1)
+		if delay *= 2; delay > l.retrymax {
+			delay = l.retrymax
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, fmt.Errorf("%w: %w", ErrLockNotAcquired, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// TryAcquire acquires the lock of key without retrying, ErrLockNotAcquired is returned if it's held by others
+func (l *Locker) TryAcquire(ctx context.Context, key string) (*Lock, error) {
+	b := make([]byte, 16)
+	crand.Read(b)
+	lk := &Lock{l: l, key: key, token: hex.EncodeToString(b), lost: make(chan struct{}), stop: make(chan struct{})}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, l.ttl)
+	defer cancel()
+	n, fence, err := l.each(ctx, func(c *Conn) (int64, error) {
+		if l.fencing {
+			return doscript(c, fenceScript, key, fenceKey(key), lk.token, l.ttl.Milliseconds())
+		}
+		err := c.DoNoReply("SET", key, lk.token, "NX", "PX", l.ttl.Milliseconds())
+		if err != nil {
+			if err == ErrNil {
+				err = nil // held by others
+			}
+			return 0, err
+		}
+		return 1, nil
+	})
+	lk.until = validuntil(start, l.ttl)
+	if n < l.quorum || time.Now().After(lk.until) {
+		if n > 0 || err != nil {
+			// releases on all instances including failed ones, since replies may be lost
+			rctx, rcancel := context.WithTimeout(context.WithoutCancel(ctx), l.ttl)
+			defer rcancel()
+			lk.release(rctx)
+		}
+		if n == 0 && err != nil {
+			return nil, err
+		}
+		return nil, ErrLockNotAcquired
+	}
+	lk.fence = fence
+	if l.renew > 0 {
+		go lk.renewloop()
+	}
+	return lk, nil
+}
+
+// validuntil returns the time a lock set at start is valid until,
+// the clocks of redis instances may drift, see the Redlock algorithm.
+func validuntil(start time.Time, ttl time.Duration) time.Time {
+	return start.Add(ttl - ttl/100 - 2*time.Millisecond)
+}
+
+func fenceKey(key string) string {
+	return key + ":fence"
+}
+
+// doscript runs s and returns the integer reply
+func doscript(c *Conn, s *Script, keysAndArgs ...interface{}) (int64, error) {
+	reply, err := s.Do(c, keysAndArgs...)
+	if err != nil {
+		return 0, err
+	}
+	defer reply.Free()
+	return reply.Integer()
+}
+
+// each calls fn with a conn of every pool concurrently,
+// and returns the number of positive results, the max result and the last err.
+func (l *Locker) each(ctx context.Context, fn func(c *Conn) (int64, error)) (n int, max int64, err error) {
+	call := func(p *Pool) (int64, error) {
+		conn, err := p.Get(ctx)
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		return fn(conn.Conn)
+	}
+	if len(l.pools) == 1 {
+		max, err = call(l.pools[0])
+		if max > 0 {
+			n = 1
+		}
+		return
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range l.pools {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, e := call(p)
+			mu.Lock()
+			defer mu.Unlock()
+			if e != nil {
+				err = e
+			} else if v > 0 {
+				n++
+				if v > max {
+					max = v
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return
+}
+
+// Key returns the key of the lock
+func (lk *Lock) Key() string {
+	return lk.key
+}
+
+// Token returns the random value of the key which identifies the holder
+func (lk *Lock) Token() string {
+	return lk.token
+}
+
+// Fence returns the fencing token of the lock, see WithLockFencing.
+// With NewRedlock it's the max counter of the majority.
+func (lk *Lock) Fence() int64 {
+	return lk.fence
+}
+
+// Until returns the time the lock is valid until, it's updated by Extend
+func (lk *Lock) Until() time.Time {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	return lk.until
+}
+
+// Lost returns a channel which is closed if the renewal fails, see WithLockRenewal
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Extend sets the ttl of the lock to ttl only if it's still held,
+// ErrLockNotHeld is returned if it expired or was taken by others, and ttl must be at least 1ms.
+func (lk *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return errLockTTL
+	}
+	l := lk.l
+	start := time.Now()
+	n, _, err := l.each(ctx, func(c *Conn) (int64, error) {
+		return doscript(c, extendScript, lk.key, lk.token, ttl.Milliseconds())
+	})
+	if n < l.quorum {
+		if err != nil {
+			return err
+		}
+		return ErrLockNotHeld
+	}
+	lk.mu.Lock()
+	lk.until = validuntil(start, ttl)
+	lk.mu.Unlock()
+	return nil
+}
+
+// Release deletes the lock only if it's still held and stops the renewal,
+// ErrLockNotHeld is returned if it expired or was taken by others.
+func (lk *Lock) Release(ctx context.Context) error {
+	lk.once.Do(func() { close(lk.stop) })
+	n, err := lk.release(ctx)
+	if n < lk.l.quorum {
+		if err != nil {
+			return err
+		}
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (lk *Lock) release(ctx context.Context) (int, error) {
+	n, _, err := lk.l.each(ctx, func(c *Conn) (int64, error) {
+		return doscript(c, releaseScript, lk.key, lk.token)
+	})
+	return n, err
+}
+
+// renewloop extends the lock every interval until it's released,
+// transient errors are retried until the lock expires.
+func (lk *Lock) renewloop() {
+	l := lk.l
+	t := time.NewTicker(l.renew)
+	defer t.Stop()
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-t.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), l.renew)
+		err := lk.Extend(ctx, l.ttl)
+		cancel()
+		if err == ErrLockNotHeld || (err != nil && time.Now().After(lk.Until())) {
+			close(lk.lost)
+			return
+		}
+	}
+}
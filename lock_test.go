@@ -0,0 +1,285 @@
+package redisgo_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+func TestScript(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	s := redisgo.NewScript(1, "return 1")
+	if s.Hash() != "e0e1f9fabfc9d4800c877a703b823ac0578ff8db" {
+		t.Fatal(s.Hash())
+	}
+	m.Expect("EVALSHA", s.Hash(), 1, "k", "v").ReturnError("NOSCRIPT No matching script. Please use EVAL.")
+	m.Expect("EVAL", "return 1", 1, "k", "v").Return(1)
+	m.Expect("EVALSHA", s.Hash(), 1, "k", "v").Return(1)
+
+	conn := redisgo.NewConn(m.Conn())
+	for i := 0; i < 2; i++ {
+		reply, err := s.Do(conn, "k", "v")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, err := reply.Integer(); err != nil || v != 1 {
+			t.Fatal(v, err)
+		}
+		reply.Free()
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lockmock expects commands of a Locker on key k and records the token
+type lockmock struct {
+	*redistest.Mock
+
+	mu    sync.Mutex
+	token string
+}
+
+func newLockMock() *lockmock {
+	return &lockmock{Mock: redistest.NewMock()}
+}
+
+func (m *lockmock) expectSet(reply interface{}) *redistest.Expectation {
+	return m.ExpectFunc("SET", func(args [][]byte) bool {
+		if len(args) != 5 || string(args[0]) != "k" || string(args[2]) != "NX" || string(args[4]) != "1000" {
+			return false
+		}
+		m.mu.Lock()
+		m.token = string(args[1])
+		m.mu.Unlock()
+		return true
+	}).Return(reply)
+}
+
+// expectScript expects EVALSHA of a script on k with the recorded token
+func (m *lockmock) expectScript(reply interface{}) *redistest.Expectation {
+	return m.ExpectFunc("EVALSHA", func(args [][]byte) bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(args) >= 4 && string(args[2]) == "k" && string(args[3]) == m.token
+	}).Return(reply)
+}
+
+func TestLocker(t *testing.T) {
+	m := newLockMock()
+	defer m.Close()
+	ctx := context.Background()
+	l := redisgo.NewLocker(redisgo.NewPool(m.Dial), time.Second, redisgo.WithLockRetry(time.Millisecond, 2*time.Millisecond))
+
+	m.expectSet(nil).Times(2) // held by others
+	m.expectSet(redistest.OK)
+	lk, err := l.Acquire(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lk.Token() != m.token || lk.Key() != "k" || time.Until(lk.Until()) < 900*time.Millisecond {
+		t.Fatal(lk.Token(), lk.Until())
+	}
+
+	m.expectScript(1)
+	if err := lk.Extend(ctx, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := lk.Extend(ctx, time.Microsecond); err == nil { // not sent as PX 0
+		t.Fatal("expect err")
+	}
+	m.expectScript(1)
+	if err := lk.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m.expectScript(0)
+	if err := lk.Release(ctx); err != redisgo.ErrLockNotHeld {
+		t.Fatal(err)
+	}
+	m.expectScript(0)
+	if err := lk.Extend(ctx, time.Second); err != redisgo.ErrLockNotHeld {
+		t.Fatal(err)
+	}
+
+	m.expectSet(nil).Times(1 << 20)
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "k"); !errors.Is(err, redisgo.ErrLockNotAcquired) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+}
+
+func TestLockerFencing(t *testing.T) {
+	m := newLockMock()
+	defer m.Close()
+	l := redisgo.NewLocker(redisgo.NewPool(m.Dial), time.Second, redisgo.WithLockFencing())
+
+	m.ExpectFunc("EVALSHA", func(args [][]byte) bool {
+		return len(args) == 6 && string(args[1]) == "2" && string(args[2]) == "k" && string(args[3]) == "k:fence"
+	}).Return(7)
+	lk, err := l.TryAcquire(context.Background(), "k")
+	if err != nil || lk.Fence() != 7 {
+		t.Fatal(lk, err)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockerRenewal(t *testing.T) {
+	m := newLockMock()
+	defer m.Close()
+	l := redisgo.NewLocker(redisgo.NewPool(m.Dial), time.Second, redisgo.WithLockRenewal(5*time.Millisecond))
+
+	m.expectSet(redistest.OK)
+	m.expectScript(1).Times(2)
+	m.expectScript(0) // taken by others
+	lk, err := l.TryAcquire(context.Background(), "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lk.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost not signaled")
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedlock(t *testing.T) {
+	ctx := context.Background()
+	mocks := make([]*lockmock, 3)
+	pools := make([]*redisgo.Pool, 3)
+	for i := range mocks {
+		mocks[i] = newLockMock()
+		defer mocks[i].Close()
+		pools[i] = redisgo.NewPool(mocks[i].Dial)
+	}
+	l := redisgo.NewRedlock(pools, time.Second)
+
+	// majority
+	mocks[0].expectSet(redistest.OK)
+	mocks[1].expectSet(nil)
+	mocks[2].expectSet(redistest.OK)
+	lk, err := l.TryAcquire(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mocks[0].expectScript(1)
+	mocks[1].expectScript(0)
+	mocks[2].expectScript(1)
+	if err := lk.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// minority is released on all instances
+	mocks[0].expectSet(redistest.OK)
+	mocks[1].expectSet(nil)
+	mocks[2].expectSet(nil)
+	for _, m := range mocks {
+		m.ExpectFunc("EVALSHA", func(args [][]byte) bool { return string(args[2]) == "k" }).Return(0)
+	}
+	if _, err := l.TryAcquire(ctx, "k"); err != redisgo.ErrLockNotAcquired {
+		t.Fatal(err)
+	}
+	for _, m := range mocks {
+		if err := m.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLockerTTL(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expect panic")
+		}
+	}()
+	redisgo.NewLocker(redisgo.NewPool(nil), time.Microsecond)
+}
+
+func TestLockerRetryClamp(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	var n int64
+	m.ExpectFunc("SET", func(args [][]byte) bool {
+		atomic.AddInt64(&n, 1)
+		return true
+	}).Return(nil).Times(1 << 20)
+
+	l := redisgo.NewLocker(redisgo.NewPool(m.Dial), time.Second, redisgo.WithLockRetry(0, -1))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "k"); !errors.Is(err, redisgo.ErrLockNotAcquired) {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&n); n > 50 { // retries every 0.5ms~1ms
+		t.Fatal("busy loop", n)
+	}
+}
+
+func TestLockerRedis(t *testing.T) {
+	p := redistest.LocalPool(t)
+	ctx := context.Background()
+	key := "redisgo:test:lock:" + time.Now().Format(time.RFC3339Nano)
+	conn, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer conn.DoNoReply("DEL", key, key+":fence")
+
+	l := redisgo.NewLocker(p, time.Second, redisgo.WithLockFencing())
+	lk, err := l.TryAcquire(ctx, key)
+	if err != nil || lk.Fence() != 1 {
+		t.Fatal(lk, err)
+	}
+	if _, err := l.TryAcquire(ctx, key); err != redisgo.ErrLockNotAcquired {
+		t.Fatal(err)
+	}
+
+	// extend
+	if err := lk.Extend(ctx, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := conn.DoInteger("PTTL", key); err != nil || ttl <= 1000 {
+		t.Fatal(ttl, err)
+	}
+
+	// release and extend with a stale token
+	if err := conn.DoNoReply("SET", key, "others", "PX", 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := lk.Extend(ctx, time.Minute); err != redisgo.ErrLockNotHeld {
+		t.Fatal(err)
+	}
+	if err := lk.Release(ctx); err != redisgo.ErrLockNotHeld {
+		t.Fatal(err)
+	}
+	if b, err := conn.DoBytes("GET", key); err != nil || string(b) != "others" {
+		t.Fatal(string(b), err)
+	}
+
+	// fence increases after the release
+	if err := conn.DoNoReply("DEL", key); err != nil {
+		t.Fatal(err)
+	}
+	if lk, err = l.TryAcquire(ctx, key); err != nil || lk.Fence() != 2 {
+		t.Fatal(lk, err)
+	}
+	if err := lk.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := conn.DoInteger("EXISTS", key); err != nil || n != 0 {
+		t.Fatal(n, err)
+	}
+}
@@ -0,0 +1,82 @@
+package redisgo
+
+import (
+	"context"
+	"net"
+)
+
+// MuxConn is a goroutine-safe redis client which is shared by concurrent callers.
+// Commands of callers are queued and written in batches with a single flush per batch,
+// and replies are matched to callers in FIFO order by a reader goroutine.
+// It's not for blocking commands, pub/sub or transactions, which affect all callers.
+type MuxConn struct {
+	c *Conn
+}
+
+// NewMuxConn creates MuxConn, the reader and writer goroutines exit after Close or any fatal error
+func NewMuxConn(conn net.Conn, ops ...Option) *MuxConn {
+	c := NewConn(conn, ops...)
+	c.p = newPipeline(c, c.maxpending)
+	return &MuxConn{c: c}
+}
+
+// Do sends command to redis and recv reply, it's safe for concurrent use.
+// It blocks if there are too many requests pending, see WithMaxPending.
+// If ctx is done before the reply received, ctx.Err() is returned,
+// and the reply will be discarded without affecting other callers.
+// Reply.Free() SHOULD be called when no longer used
+func (m *MuxConn) Do(ctx context.Context, cmd string, args ...interface{}) (*Reply, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.c.doasync(ctx, nil, cmd, args).Wait(ctx)
+}
+
+// DoAsync sends command to redis and returns the Future of the reply, it's safe for concurrent use.
+func (m *MuxConn) DoAsync(cmd string, args ...interface{}) *Future {
+	return m.c.doasync(context.Background(), nil, cmd, args)
+}
+
+// DoNoReply wraps Do() and Reply.Err()
+func (m *MuxConn) DoNoReply(ctx context.Context, cmd string, args ...interface{}) error {
+	reply, err := m.Do(ctx, cmd, args...)
+	if err != nil {
+		return err
+	}
+	defer reply.Free()
+	return reply.Err()
+}
+
+// DoBytes wraps Do() and Reply.Bytes()
+func (m *MuxConn) DoBytes(ctx context.Context, cmd string, args ...interface{}) ([]byte, error) {
+	reply, err := m.Do(ctx, cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Free()
+	b, err := reply.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
+
+// DoInteger wraps Do() and Reply.Integer()
+func (m *MuxConn) DoInteger(ctx context.Context, cmd string, args ...interface{}) (int64, error) {
+	reply, err := m.Do(ctx, cmd, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer reply.Free()
+	return reply.Integer()
+}
+
+// Close closes the underlying connection, pending requests fail with error
+func (m *MuxConn) Close() error {
+	return m.c.Close()
+}
+
+// Err returns the fatal err which closes the MuxConn, or nil if it's alive
+func (m *MuxConn) Err() error {
+	return m.c.Err()
+}
@@ -0,0 +1,175 @@
+package redisgo_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+// countconn counts Write calls
+type countconn struct {
+	net.Conn
+	writes int64
+}
+
+func (c *countconn) Write(b []byte) (int, error) {
+	atomic.AddInt64(&c.writes, 1)
+	return c.Conn.Write(b)
+}
+
+func TestMuxConn(t *testing.T) {
+	s := redistest.NewUnstartedServer()
+	defer s.Close()
+	cc := &countconn{Conn: redistest.NewFaultConn(s.Pipe(), redistest.Faults{Latency: time.Millisecond})}
+	m := redisgo.NewMuxConn(cc, redisgo.WithMaxPending(16))
+	defer m.Close()
+
+	const goroutines, n = 50, 20
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := "k" + strconv.Itoa(i)
+			for j := 1; j <= n; j++ {
+				v, err := m.DoInteger(ctx, "INCRBY", k, i)
+				if err == nil && v != int64(i*j) {
+					err = fmt.Errorf("expect %d, get %d", i*j, v)
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if w := atomic.LoadInt64(&cc.writes); w*2 > goroutines*n {
+		t.Fatal("too many writes", w)
+	}
+
+	// invalid arg only fails the request
+	if _, err := m.Do(ctx, "SET", "k", struct{}{}); err != redisgo.ErrInvalidArgType {
+		t.Fatal(err)
+	}
+	if b, err := m.DoBytes(ctx, "ECHO", "hello"); err != nil || string(b) != "hello" {
+		t.Fatal(b, err)
+	}
+}
+
+func TestMuxConnCancel(t *testing.T) {
+	mock := redistest.NewMock()
+	defer mock.Close()
+	mock.Expect("GET", "slow").Return("1").Delay(50 * time.Millisecond)
+	mock.Expect("GET", "fast").Return("2")
+	m := redisgo.NewMuxConn(mock.Conn())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.Do(ctx, "GET", "slow"); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	// canceled before written
+	if _, err := m.Do(ctx, "GET", "never"); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	b, err := m.DoBytes(context.Background(), "GET", "fast")
+	if err != nil || string(b) != "2" {
+		t.Fatal(b, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Close()
+	if _, err := m.Do(context.Background(), "GET", "fast"); err != redisgo.ErrNotSent {
+		t.Fatal(err)
+	}
+}
+
+func TestMuxConnCancelPending(t *testing.T) {
+	c0, c1 := net.Pipe() // c1 never reads
+	defer c1.Close()
+	m := redisgo.NewMuxConn(c0, redisgo.WithMaxPending(1), redisgo.WithWriteTimeout(time.Second))
+	defer m.Close()
+
+	m.DoAsync("PING")
+	time.Sleep(10 * time.Millisecond) // the writer is blocked
+	m.DoAsync("PING")                 // waiting to be written
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	t0 := time.Now()
+	if _, err := m.Do(ctx, "PING"); err != context.DeadlineExceeded || time.Since(t0) > 500*time.Millisecond {
+		t.Fatal(err, time.Since(t0))
+	}
+
+	// Err is safe for concurrent use with Close
+	go m.Close()
+	for m.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMuxConnBroken(t *testing.T) {
+	mock := redistest.NewMock()
+	defer mock.Close()
+	mock.Expect("GET", "a").Return("1").Delay(20 * time.Millisecond)
+	mock.Expect("GET", "b").NetError(net.ErrClosed)
+	m := redisgo.NewMuxConn(mock.Conn())
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	for _, k := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			_, err := m.Do(context.Background(), "GET", k)
+			if k == "a" && err != nil {
+				t.Error(k, err)
+			}
+			if k != "a" && err == nil {
+				t.Error(k, "expect err")
+			}
+		}(k)
+		time.Sleep(5 * time.Millisecond) // a, b, c in order
+	}
+	wg.Wait()
+	if m.Err() == nil {
+		t.Fatal("expect err")
+	}
+}
+
+func BenchmarkMuxConn(b *testing.B) {
+	s := redistest.NewServer()
+	defer s.Close()
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		b.Fatal(err)
+	}
+	m := redisgo.NewMuxConn(conn)
+	defer m.Close()
+	ctx := context.Background()
+	b.SetParallelism(16)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := m.DoBytes(ctx, "ECHO", "hello"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -9,6 +9,12 @@ type options struct {
 	wbuf     int
 	rtimeout time.Duration
 	wtimeout time.Duration
+
+	maxbulklen  int
+	maxarraylen int
+	maxdepth    int
+
+	maxpending int
 }
 
 var defaultoptions = options{
@@ -16,6 +22,12 @@ var defaultoptions = options{
 	wbuf:     2048,
 	rtimeout: 30 * time.Second,
 	wtimeout: 30 * time.Second,
+
+	maxbulklen:  512 * 1024 * 1024, // same as proto-max-bulk-len of redis
+	maxarraylen: 16 * 1024 * 1024,
+	maxdepth:    64,
+
+	maxpending: 1024,
 }
 
 // WithReadBuffer set read buffer size of connection
@@ -49,3 +61,40 @@ func WithWriteTimeout(t time.Duration) Option {
 		return opt
 	}
 }
+
+// WithMaxBulkLen limits the length of a bulk string reply, default: 512MB.
+// Conn.Recv returns protocol err if the limit exceeded
+func WithMaxBulkLen(n int) Option {
+	return func(opt options) options {
+		opt.maxbulklen = n
+		return opt
+	}
+}
+
+// WithMaxArrayLen limits the number of elements of an array reply, default: 16M.
+// Conn.Recv returns protocol err if the limit exceeded
+func WithMaxArrayLen(n int) Option {
+	return func(opt options) options {
+		opt.maxarraylen = n
+		return opt
+	}
+}
+
+// WithMaxDepth limits the nesting depth of array replies, default: 64.
+// Conn.Recv returns protocol err if the limit exceeded
+func WithMaxDepth(n int) Option {
+	return func(opt options) options {
+		opt.maxdepth = n
+		return opt
+	}
+}
+
+// WithMaxPending limits the number of requests of MuxConn waiting to be written,
+// and the number of requests waiting for replies, default: 1024.
+// MuxConn.Do blocks if the limit exceeded
+func WithMaxPending(n int) Option {
+	return func(opt options) options {
+		opt.maxpending = n
+		return opt
+	}
+}
@@ -2,6 +2,10 @@ package redisgo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -13,6 +17,8 @@ type PoolConn struct {
 	p         *Pool
 	freedAt   time.Time
 	createdAt time.Time
+	b         *borrow // debug mode only
+	bgen      uint64  // generation of the breaker when borrowed
 }
 
 // CreatedAt returns the create time of the conn
@@ -22,11 +28,24 @@ func (c *PoolConn) CreatedAt() time.Time {
 
 // Close puts PoolConn back to the pool
 func (c *PoolConn) Close() {
-	if c.Conn.pd != 0 {
-		c.Conn.Close()
-	}
 	p := c.p
+	p.untrack(c)
 	c.p = nil
+	if p.breaker != nil {
+		err := c.Conn.Err()
+		p.breaker.done(c.bgen, err == nil || err == errClosed)
+	}
+	if pd := c.Conn.pd; pd > 0 && c.Conn.p == nil {
+		p.warn(fmt.Sprintf("redisgo: PoolConn closed with %d pending replies", pd))
+		if p.draintimeout <= 0 || c.Conn.drain(p.draintimeout, p.drainbytes) != nil {
+			c.Conn.Close()
+		}
+	} else if pd < 0 { // more Recv than Send, like subscribed, not reusable
+		c.Conn.Close()
+	}
+	if c.Conn.p != nil {
+		c.Conn.Close()
+	}
 	p.put(c)
 }
 
@@ -42,7 +61,25 @@ type Pool struct {
 
 	active int64
 
-	ch chan *PoolConn
+	draintimeout time.Duration
+	drainbytes   int64
+	warnfunc     func(msg string)
+
+	debug   bool
+	mu      sync.Mutex
+	borrows map[*borrow]struct{}
+
+	idle      idlestore
+	idleorder IdleOrder
+
+	dialsem    chan struct{} // limits concurrent dials if not nil
+	backoffmin time.Duration
+	backoffmax time.Duration
+	dialfails  int // consecutive dial failures, guarded by mu
+	dialerr    error
+	dialuntil  time.Time // no dials before it if dialfails > 0
+
+	breaker *Breaker
 
 	nowfunc func() time.Time
 }
@@ -83,6 +120,72 @@ func WithMaxConnTime(t time.Duration) PoolOption {
 
 type DialFunc func(ctx context.Context) (*Conn, error)
 
+// IdleOrder is the order of getting idle conns from Pool
+type IdleOrder int
+
+const (
+	// LIFO gets the most recently used conn first, so that surplus conns age out, default
+	LIFO IdleOrder = iota
+
+	// FIFO gets the least recently used conn first, so that load spreads over conns.
+	//
+	// Idle conns are stored per P (the processor of the runtime) for reducing contention,
+	// both orders hold within the conns returned on the same P only.
+	FIFO
+)
+
+// WithIdleOrder sets the order of getting idle conns, default: LIFO.
+func WithIdleOrder(o IdleOrder) PoolOption {
+	return func(p *Pool) {
+		p.idleorder = o
+	}
+}
+
+// WithMaxConcurrentDials limits the number of concurrent dials to n.
+// Pool.Get waits for other dials, and reuses the conns returned meanwhile.
+func WithMaxConcurrentDials(n int) PoolOption {
+	return func(p *Pool) {
+		if n > 0 {
+			p.dialsem = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithDialBackoff stops dialing for a while after dial failures, default: disabled.
+// The duration starts from min and doubles for consecutive failures up to max,
+// Pool.Get returns ErrDialBackoff wrapping the last dial err without dialing before that.
+func WithDialBackoff(min, max time.Duration) PoolOption {
+	return func(p *Pool) {
+		p.backoffmin = min
+		p.backoffmax = max
+	}
+}
+
+// WithBreaker sets the circuit breaker of the pool, default: disabled.
+// Pool.Get returns ErrCircuitOpen immediately while it's open, see NewBreaker.
+func WithBreaker(b *Breaker) PoolOption {
+	return func(p *Pool) {
+		p.breaker = b
+	}
+}
+
+// WithDrainPending drains pending replies of a conn returned to the pool within timeout and maxbytes,
+// instead of closing it. The conn is closed if failed to drain. default: disabled
+func WithDrainPending(timeout time.Duration, maxbytes int) PoolOption {
+	return func(p *Pool) {
+		p.draintimeout = timeout
+		p.drainbytes = int64(maxbytes)
+	}
+}
+
+// WithWarnFunc sets fn for reporting suspected bugs of callers,
+// like returning conns with pending replies.
+func WithWarnFunc(fn func(msg string)) PoolOption {
+	return func(p *Pool) {
+		p.warnfunc = fn
+	}
+}
+
 // NewPool creates a instance of Pool with dialfunc
 func NewPool(dialfunc DialFunc, ops ...PoolOption) *Pool {
 	p := &Pool{dial: dialfunc}
@@ -90,14 +193,15 @@ func NewPool(dialfunc DialFunc, ops ...PoolOption) *Pool {
 	for _, op := range ops {
 		op(p)
 	}
-	p.ch = make(chan *PoolConn, p.maxIdle)
+	p.idle.init(p.maxIdle, p.idleorder)
+	p.borrows = make(map[*borrow]struct{})
 	p.nowfunc = time.Now
 	return p
 }
 
 // Idle returns idle conn number
 func (p *Pool) Idle() int {
-	return len(p.ch)
+	return p.idle.len()
 }
 
 // Active returns active conn number
@@ -106,20 +210,39 @@ func (p *Pool) Active() int {
 }
 
 // Get returns PoolConn from pool. ctx is passed to DialFunc.
-func (p *Pool) Get(ctx context.Context) (*PoolConn, error) {
-	select {
-	case conn := <-p.ch:
-		now := p.nowfunc()
-		if (p.maxIdleTime > 0 && now.Sub(conn.freedAt) > p.maxIdleTime) ||
-			(p.maxConnTime > 0 && now.Sub(conn.CreatedAt()) > p.maxConnTime) {
-			p.closeconn(conn)
-			return p.Get(ctx)
+func (p *Pool) Get(ctx context.Context) (conn *PoolConn, err error) {
+	if p.breaker != nil {
+		gen, berr := p.breaker.allow()
+		if berr != nil {
+			return nil, berr
 		}
-		conn.p = p
+		defer func() { p.got(gen, conn, err) }()
+	}
+	if conn := p.getidle(); conn != nil {
+		p.track(conn)
 		return conn, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := p.backoff(); err != nil {
+		return nil, err
+	}
+	if p.dialsem != nil {
+		select {
+		case p.dialsem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		defer func() { <-p.dialsem }()
+		// conns may be returned or dialed by others while waiting
+		if conn := p.getidle(); conn != nil {
+			p.track(conn)
+			return conn, nil
+		}
+		if err := p.backoff(); err != nil {
+			return nil, err
+		}
 	}
 	active := atomic.AddInt64(&p.active, 1)
 	if p.maxActive > 0 && active > p.maxActive {
@@ -127,11 +250,82 @@ func (p *Pool) Get(ctx context.Context) (*PoolConn, error) {
 		return nil, ErrMaxActive
 	}
 	c, err := p.dial(ctx)
+	p.dialed(err)
 	if err != nil {
 		atomic.AddInt64(&p.active, -1)
 		return nil, err
 	}
-	return &PoolConn{Conn: c, p: p, createdAt: p.nowfunc()}, nil
+	conn = &PoolConn{Conn: c, p: p, createdAt: p.nowfunc()}
+	if p.debug {
+		runtime.SetFinalizer(conn, finalizePoolConn)
+	}
+	p.track(conn)
+	return conn, nil
+}
+
+// got reports the result of Get to the breaker,
+// the result of a conn is reported after it's returned.
+func (p *Pool) got(gen uint64, conn *PoolConn, err error) {
+	switch {
+	case err == nil:
+		conn.bgen = gen
+	case err == ErrMaxActive || err == context.Canceled || errors.Is(err, ErrDialBackoff):
+		p.breaker.cancel(gen) // not failures of redis, timeouts are, and the dial failure was counted
+	default:
+		p.breaker.done(gen, false)
+	}
+}
+
+// getidle returns an idle conn which is not expired, or nil if no such conns
+func (p *Pool) getidle() *PoolConn {
+	for conn := p.idle.get(); conn != nil; conn = p.idle.get() {
+		now := p.nowfunc()
+		if (p.maxIdleTime > 0 && now.Sub(conn.freedAt) > p.maxIdleTime) ||
+			(p.maxConnTime > 0 && now.Sub(conn.CreatedAt()) > p.maxConnTime) {
+			p.closeconn(conn)
+			continue
+		}
+		conn.p = p
+		return conn
+	}
+	return nil
+}
+
+// backoff returns ErrDialBackoff if dials are not allowed after failures
+func (p *Pool) backoff() error {
+	if p.backoffmin <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dialfails > 0 && p.nowfunc().Before(p.dialuntil) {
+		return fmt.Errorf("%w: %w", ErrDialBackoff, p.dialerr)
+	}
+	return nil
+}
+
+// dialed updates the backoff state with the result of a dial
+func (p *Pool) dialed(err error) {
+	if p.backoffmin <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == nil {
+		p.dialfails = 0
+		p.dialerr = nil
+		return
+	}
+	d := p.backoffmin
+	for i := 0; i < p.dialfails && d < p.backoffmax; i++ {
+		d *= 2
+	}
+	if p.backoffmax > 0 && d > p.backoffmax {
+		d = p.backoffmax
+	}
+	p.dialfails++
+	p.dialerr = err
+	p.dialuntil = p.nowfunc().Add(d)
 }
 
 func (p *Pool) put(conn *PoolConn) {
@@ -144,14 +338,17 @@ func (p *Pool) put(conn *PoolConn) {
 		return
 	}
 	conn.freedAt = p.nowfunc()
-	select {
-	case p.ch <- conn:
-		return
-	default:
+	if !p.idle.put(conn) {
 		p.closeconn(conn)
 	}
 }
 
+func (p *Pool) warn(msg string) {
+	if p.warnfunc != nil {
+		p.warnfunc(msg)
+	}
+}
+
 func (p *Pool) closeconn(conn *PoolConn) {
 	atomic.AddInt64(&p.active, -1)
 	conn.Conn.Close()
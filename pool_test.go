@@ -2,8 +2,18 @@ package redisgo
 
 import (
 	"context"
+	"errors"
+	"net"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
+
+	"github.com/xiaost/redisgo/resp"
 )
 
 func TestPoolConn(t *testing.T) {
@@ -68,3 +78,457 @@ func TestPoolConn(t *testing.T) {
 	}
 	c1.Close()
 }
+
+// dialpipe is a DialFunc of conns served by a goroutine which replies ECHO,
+// and never replies commands other than ECHO.
+func dialpipe(ctx context.Context) (*Conn, error) {
+	c0, c1 := net.Pipe()
+	go func() {
+		defer c1.Close()
+		dec := resp.NewDecoder(c1)
+		enc := resp.NewEncoder(c1)
+		r := resp.NewReply()
+		for dec.DecodeCommand(r) == nil {
+			aa, _ := r.Array()
+			name, _ := aa[0].Bytes()
+			var b []byte
+			if len(aa) > 1 {
+				b, _ = aa[1].Bytes()
+			}
+			switch string(name) {
+			case "ECHO":
+				enc.WriteBulk(b)
+			case "SUBSCRIBE": // replies the confirmation and a message
+				enc.WriteArrayHeader(3)
+				enc.WriteBulkString("subscribe")
+				enc.WriteBulk(b)
+				enc.WriteInteger(1)
+				enc.WriteArrayHeader(3)
+				enc.WriteBulkString("message")
+				enc.WriteBulk(b)
+				enc.WriteBulkString("hello")
+			default:
+				continue
+			}
+			if enc.Flush() != nil {
+				return
+			}
+		}
+	}()
+	return NewConn(c0), nil
+}
+
+func TestPoolConnDrain(t *testing.T) {
+	var warns []string
+	ctx := context.Background()
+	p := NewPool(dialpipe,
+		WithDrainPending(20*time.Millisecond, 100),
+		WithWarnFunc(func(msg string) { warns = append(warns, msg) }))
+
+	c0, _ := p.Get(ctx)
+	c0.Send("ECHO", "a")
+	c0.Send("ECHO", "b")
+	c0.Close()
+	if p.Idle() != 1 || len(warns) != 1 || !strings.Contains(warns[0], "2 pending") {
+		t.Fatal(p.Idle(), warns)
+	}
+	c1, _ := p.Get(ctx)
+	if c1 != c0 {
+		t.Fatal("conn not reused")
+	}
+	if b, err := c1.DoBytes("ECHO", "c"); err != nil || string(b) != "c" {
+		t.Fatal(string(b), err)
+	}
+
+	// exceeds maxbytes
+	c1.Send("ECHO", strings.Repeat("x", 200))
+	c1.Close()
+	if p.Idle() != 0 || p.Active() != 0 {
+		t.Fatal(p.Idle(), p.Active())
+	}
+
+	// exceeds timeout
+	c2, _ := p.Get(ctx)
+	c2.Send("HANG")
+	t0 := time.Now()
+	c2.Close()
+	if p.Idle() != 0 || p.Active() != 0 || time.Since(t0) < 20*time.Millisecond {
+		t.Fatal(p.Idle(), p.Active())
+	}
+
+	// subscribed with more Recv than Send
+	c3, _ := p.Get(ctx)
+	c3.Send("SUBSCRIBE", "ch")
+	var r Reply
+	for i := 0; i < 2; i++ {
+		if err := c3.Recv(&r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c3.Close()
+	if p.Idle() != 0 || p.Active() != 0 {
+		t.Fatal(p.Idle(), p.Active())
+	}
+
+	// exceeds maxbytes with a partial reply
+	c4, _ := p.Get(ctx)
+	c4.Send("ECHO", strings.Repeat("x", 1<<20))
+	t0 = time.Now()
+	c4.Close()
+	if p.Idle() != 0 || p.Active() != 0 || time.Since(t0) >= 20*time.Millisecond {
+		t.Fatal(p.Idle(), p.Active(), time.Since(t0))
+	}
+
+	// drain disabled
+	p = NewPool(dialpipe)
+	c5, _ := p.Get(ctx)
+	c5.Send("ECHO", "a")
+	c5.Close()
+	if p.Idle() != 0 || p.Active() != 0 {
+		t.Fatal(p.Idle(), p.Active())
+	}
+}
+
+func TestPoolLeaks(t *testing.T) {
+	var now time.Time
+	warns := make(chan string, 1)
+	ctx := context.Background()
+	p := NewPool(dialpipe, WithDebug(true), WithWarnFunc(func(msg string) { warns <- msg }))
+	p.nowfunc = func() time.Time { return now }
+
+	c0, _ := p.Get(ctx)
+	now = now.Add(time.Minute)
+	c1, _ := p.Get(ctx)
+	now = now.Add(time.Second)
+	leaks := p.Leaks(30 * time.Second)
+	if len(leaks) != 1 || !strings.Contains(leaks[0].Stack, "TestPoolLeaks") {
+		t.Fatal(leaks)
+	}
+	if len(p.Leaks(0)) != 2 {
+		t.Fatal(p.Leaks(0))
+	}
+	c0.Close()
+	c1.Close()
+	if len(p.Leaks(0)) != 0 {
+		t.Fatal(p.Leaks(0))
+	}
+
+	func() {
+		p.Get(ctx)
+		p.Get(ctx)
+	}()
+	for i := 0; i < 2; i++ {
+		runtime.GC()
+		select {
+		case msg := <-warns:
+			if !strings.Contains(msg, "garbage collected") || !strings.Contains(msg, "TestPoolLeaks") {
+				t.Fatal(msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("finalizer not called")
+		}
+	}
+	if p.Active() != 0 {
+		t.Fatal(p.Active())
+	}
+}
+
+// newidlestore returns idlestore with shards of capacities in caps
+func newidlestore(order IdleOrder, caps ...int) *idlestore {
+	s := &idlestore{shards: make([]idleshard, len(caps)), fifo: order == FIFO}
+	for i, n := range caps {
+		s.shards[i].conns = make([]*PoolConn, n)
+	}
+	return s
+}
+
+func TestIdleStore(t *testing.T) {
+	if sz := unsafe.Sizeof(idleshard{}); sz%64 != 0 {
+		t.Fatal("idleshard size", sz)
+	}
+	var s idlestore
+	s.init(3, LIFO)
+	if len(s.shards) != min(runtime.GOMAXPROCS(0), 3) {
+		t.Fatal(len(s.shards))
+	}
+	for _, s := range []*idlestore{&s, newidlestore(LIFO, 1, 1, 1), newidlestore(LIFO, 0, 3)} {
+		cc := []*PoolConn{{}, {}, {}, {}}
+		for i, c := range cc {
+			if ok := s.put(c); ok != (i < 3) {
+				t.Fatal(i, ok)
+			}
+		}
+		if s.len() != 3 {
+			t.Fatal(s.len())
+		}
+		got := map[*PoolConn]bool{}
+		for c := s.get(); c != nil; c = s.get() { // steals from other shards
+			got[c] = true
+		}
+		if len(got) != 3 || got[cc[3]] || s.len() != 0 {
+			t.Fatal(len(got), s.len())
+		}
+	}
+
+	s.init(0, LIFO)
+	if s.put(&PoolConn{}) || s.get() != nil {
+		t.Fatal("not empty")
+	}
+}
+
+func TestIdleStoreOrder(t *testing.T) {
+	for _, order := range []IdleOrder{LIFO, FIFO} {
+		s := newidlestore(order, 3)
+		cc := []*PoolConn{{}, {}, {}, {}}
+		var got []*PoolConn
+		s.put(cc[0])
+		s.put(cc[1])
+		s.put(cc[2])
+		got = append(got, s.get())
+		s.put(cc[3]) // wraps around in FIFO order
+		for c := s.get(); c != nil; c = s.get() {
+			got = append(got, c)
+		}
+		expect := []*PoolConn{cc[2], cc[3], cc[1], cc[0]}
+		if order == FIFO {
+			expect = cc
+		}
+		if !slices.Equal(got, expect) {
+			t.Fatal(order, got)
+		}
+	}
+}
+
+func TestPoolDialBackoff(t *testing.T) {
+	var now time.Time
+	var dials int
+	var dialerr error = errors.New("connection refused")
+	dialfunc := func(ctx context.Context) (*Conn, error) {
+		dials++
+		if dialerr != nil {
+			return nil, dialerr
+		}
+		return NewConn(&FakeConn{}), nil
+	}
+	ctx := context.Background()
+	p := NewPool(dialfunc, WithDialBackoff(time.Second, 3*time.Second))
+	p.nowfunc = func() time.Time { return now }
+
+	expect := func(dialed bool, backoff bool) {
+		t.Helper()
+		n := dials
+		_, err := p.Get(ctx)
+		if (dials > n) != dialed {
+			t.Fatal("dialed", dials > n)
+		}
+		if errors.Is(err, ErrDialBackoff) != backoff || !errors.Is(err, dialerr) {
+			t.Fatal(err)
+		}
+		if p.Active() != 0 {
+			t.Fatal(p.Active())
+		}
+	}
+	expect(true, false)
+	expect(false, true)
+	now = now.Add(time.Second)
+	expect(true, false) // 2s
+	now = now.Add(time.Second)
+	expect(false, true)
+	now = now.Add(time.Second)
+	expect(true, false) // 3s, max
+	now = now.Add(3 * time.Second)
+	expect(true, false)
+	now = now.Add(3 * time.Second)
+	dialerr = nil
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	dialerr = errors.New("connection refused")
+	c, _ = p.Get(ctx) // idle conn reused
+	c.Conn.Close()
+	c.Close()
+	expect(true, false)
+	now = now.Add(time.Second) // reset to min after success
+	expect(true, false)
+}
+
+func TestPoolMaxConcurrentDials(t *testing.T) {
+	var dials, maxdials int32
+	dialfunc := func(ctx context.Context) (*Conn, error) {
+		n := atomic.AddInt32(&dials, 1)
+		defer atomic.AddInt32(&dials, -1)
+		for {
+			m := atomic.LoadInt32(&maxdials)
+			if n <= m || atomic.CompareAndSwapInt32(&maxdials, m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return NewConn(&FakeConn{}), nil
+	}
+	ctx := context.Background()
+	p := NewPool(dialfunc, WithMaxConcurrentDials(2), WithMaxIdle(10))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := p.Get(ctx)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			c.Close()
+		}()
+	}
+	wg.Wait()
+	if maxdials != 2 {
+		t.Fatal(maxdials)
+	}
+	if p.Active() != p.Idle() || p.Active() >= 10 {
+		t.Fatal(p.Active(), p.Idle())
+	}
+
+	// waiting for dials is canceled by ctx
+	p = NewPool(func(ctx context.Context) (*Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithMaxConcurrentDials(1))
+	ctx0, cancel0 := context.WithCancel(ctx)
+	go p.Get(ctx0)
+	time.Sleep(5 * time.Millisecond)
+	ctx1, cancel1 := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel1()
+	if _, err := p.Get(ctx1); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+	cancel0()
+}
+
+func benchmarkPool(b *testing.B, maxIdle int) {
+	dialfunc := func(ctx context.Context) (*Conn, error) {
+		return NewConn(&FakeConn{}), nil
+	}
+	p := NewPool(dialfunc, WithMaxIdle(maxIdle))
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c, err := p.Get(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			c.Close()
+		}
+	})
+}
+
+func BenchmarkPool(b *testing.B) {
+	benchmarkPool(b, 2*runtime.GOMAXPROCS(0))
+}
+
+// chanpool is Get and Close of the previous Pool which stores idle conns in a channel,
+// for comparing with Pool
+type chanpool struct {
+	dial    DialFunc
+	ch      chan *PoolConn
+	active  int64
+	nowfunc func() time.Time
+}
+
+func (p *chanpool) get(ctx context.Context) (*PoolConn, error) {
+	select {
+	case conn := <-p.ch:
+		conn.freedAt = p.nowfunc() // checked by maxIdleTime
+		return conn, nil
+	default:
+	}
+	atomic.AddInt64(&p.active, 1)
+	c, err := p.dial(ctx)
+	if err != nil {
+		atomic.AddInt64(&p.active, -1)
+		return nil, err
+	}
+	return &PoolConn{Conn: c, createdAt: p.nowfunc()}, nil
+}
+
+func (p *chanpool) put(conn *PoolConn) {
+	conn.freedAt = p.nowfunc()
+	select {
+	case p.ch <- conn:
+	default:
+		atomic.AddInt64(&p.active, -1)
+		conn.Conn.Close()
+	}
+}
+
+func BenchmarkPoolChan(b *testing.B) {
+	p := &chanpool{
+		dial: func(ctx context.Context) (*Conn, error) {
+			return NewConn(&FakeConn{}), nil
+		},
+		ch:      make(chan *PoolConn, 2*runtime.GOMAXPROCS(0)),
+		nowfunc: time.Now,
+	}
+	ctx := context.Background()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c, err := p.get(ctx)
+			if err != nil {
+				b.Fatal(err)
+			}
+			p.put(c)
+		}
+	})
+}
+
+// chanstore is the previous idle store of Pool, for comparing with idlestore
+type chanstore struct {
+	ch chan *PoolConn
+}
+
+func (s *chanstore) put(c *PoolConn) bool {
+	select {
+	case s.ch <- c:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *chanstore) get() *PoolConn {
+	select {
+	case c := <-s.ch:
+		return c
+	default:
+		return nil
+	}
+}
+
+func BenchmarkIdleStore(b *testing.B) {
+	var s idlestore
+	s.init(2*runtime.GOMAXPROCS(0), LIFO)
+	b.RunParallel(func(pb *testing.PB) {
+		c := &PoolConn{}
+		for pb.Next() {
+			s.put(c)
+			if c = s.get(); c == nil {
+				c = &PoolConn{}
+			}
+		}
+	})
+}
+
+func BenchmarkIdleStoreChan(b *testing.B) {
+	s := chanstore{ch: make(chan *PoolConn, 2*runtime.GOMAXPROCS(0))}
+	b.RunParallel(func(pb *testing.PB) {
+		c := &PoolConn{}
+		for pb.Next() {
+			s.put(c)
+			if c = s.get(); c == nil {
+				c = &PoolConn{}
+			}
+		}
+	})
+}
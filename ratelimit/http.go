@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeyFunc returns the key of a request, requests with an empty key are not limited
+type KeyFunc func(r *http.Request) string
+
+// KeyByRemoteAddr returns a KeyFunc which keys requests by the IP of r.RemoteAddr with prefix.
+// r.RemoteAddr is the address of the proxy if the server is behind any.
+func KeyByRemoteAddr(prefix string) KeyFunc {
+	return func(r *http.Request) string {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return prefix + host
+	}
+}
+
+// MiddlewareOption represents options of Middleware
+type MiddlewareOption func(m *middleware)
+
+// WithErrorHandler sets the handler of requests which can not be checked because of err,
+// default: requests are served as if they're allowed.
+func WithErrorHandler(fn func(w http.ResponseWriter, r *http.Request, err error)) MiddlewareOption {
+	return func(m *middleware) {
+		m.errfunc = fn
+	}
+}
+
+// WithDeniedHandler sets the handler of denied requests,
+// default: 429 Too Many Requests is replied.
+func WithDeniedHandler(h http.Handler) MiddlewareOption {
+	return func(m *middleware) {
+		m.denied = h
+	}
+}
+
+type middleware struct {
+	l       *Limiter
+	key     KeyFunc
+	errfunc func(w http.ResponseWriter, r *http.Request, err error)
+	denied  http.Handler
+	next    http.Handler
+}
+
+// Middleware returns a net/http middleware which limits requests by l with the key returned by key.
+// The headers RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset are set for every checked request,
+// and Retry-After is set for denied requests. RateLimit-Limit is the max requests allowed at once,
+// which is Burst of GCRA and TokenBucket, or Rate of SlidingWindow.
+func Middleware(l *Limiter, key KeyFunc, ops ...MiddlewareOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		m := &middleware{l: l, key: key, next: next}
+		for _, op := range ops {
+			op(m)
+		}
+		return m
+	}
+}
+
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k := m.key(r)
+	if k == "" {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	res, err := m.l.Allow(r.Context(), k)
+	if err != nil {
+		if m.errfunc != nil {
+			m.errfunc(w, r, err)
+		} else {
+			m.next.ServeHTTP(w, r)
+		}
+		return
+	}
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.FormatInt(m.l.capacity, 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(res.Remaining, 10))
+	h.Set("RateLimit-Reset", seconds(res.ResetAfter))
+	if res.Allowed {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	if res.RetryAfter >= 0 {
+		h.Set("Retry-After", seconds(res.RetryAfter))
+	}
+	if m.denied != nil {
+		m.denied.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// seconds formats d in seconds rounded up, since headers don't accept fractions
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64((d+time.Second-1)/time.Second), 10)
+}
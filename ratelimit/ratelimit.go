@@ -0,0 +1,249 @@
+// Package ratelimit implements rate limiters with atomic Lua scripts run by redis,
+// so that limits are shared by all processes using the same redis.
+// The clock of redis is used instead of local clocks, it requires redis 5.0 or later.
+//
+//	l := ratelimit.NewGCRA(pool, ratelimit.PerSecond(10))
+//	res, err := l.Allow(ctx, "user:1")
+//	if err == nil && !res.Allowed {
+//		time.Sleep(res.RetryAfter)
+//	}
+package ratelimit
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/xiaost/redisgo"
+)
+
+// Limit represents the rate of requests
+type Limit struct {
+	Rate   int64         // requests allowed per Period
+	Period time.Duration // the window of SlidingWindow
+
+	// Burst is the max requests allowed at once of GCRA and the capacity of TokenBucket,
+	// Rate is used if it's 0.
+	Burst int64
+}
+
+// PerSecond returns Limit of rate requests per second
+func PerSecond(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute returns Limit of rate requests per minute
+func PerMinute(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+// PerHour returns Limit of rate requests per hour
+func PerHour(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+// check panics if l is invalid, Rate <= 0 would be an infinite interval of requests,
+// and a Period less than 1ms could not be the ttl of keys.
+func (l Limit) check() {
+	if l.Rate <= 0 || l.Period < time.Millisecond {
+		panic(fmt.Sprintf("ratelimit: invalid limit %d per %v", l.Rate, l.Period))
+	}
+}
+
+func (l Limit) burst() int64 {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// interval returns the time of one request in microseconds
+func (l Limit) interval() float64 {
+	return float64(l.Period) / float64(time.Microsecond) / float64(l.Rate)
+}
+
+// Result represents the result of Limiter.AllowN
+type Result struct {
+	Allowed bool
+
+	// Remaining is the number of requests allowed right now after this one
+	Remaining int64
+
+	// RetryAfter is the time to wait until the request would be allowed if it's denied,
+	// or -1 if it would never be allowed since n exceeds the limit. It's 0 if allowed.
+	RetryAfter time.Duration
+
+	// ResetAfter is the time until the limiter of the key is back to its initial state
+	ResetAfter time.Duration
+}
+
+// Limiter limits requests of keys by one of the algorithms
+type Limiter struct {
+	p        *redisgo.Pool
+	limit    Limit
+	capacity int64 // max requests allowed at once
+	script   *redisgo.Script
+	args     func(n int64) []interface{}
+}
+
+// NewGCRA returns Limiter of the generic cell rate algorithm,
+// which allows Burst requests at once and spreads the rest evenly over Period.
+// It stores a timestamp per key.
+// The constructors panic if Rate <= 0 or Period < 1ms.
+func NewGCRA(p *redisgo.Pool, limit Limit) *Limiter {
+	limit.check()
+	return &Limiter{p: p, limit: limit, capacity: limit.burst(), script: gcraScript, args: func(n int64) []interface{} {
+		return []interface{}{limit.burst(), limit.interval(), n}
+	}}
+}
+
+// NewSlidingWindow returns Limiter of the sliding window log,
+// which allows Rate requests within any Period exactly.
+// It stores a sorted set with an entry per request of Period.
+func NewSlidingWindow(p *redisgo.Pool, limit Limit) *Limiter {
+	limit.check()
+	return &Limiter{p: p, limit: limit, capacity: limit.Rate, script: slidingWindowScript, args: func(n int64) []interface{} {
+		b := make([]byte, 8)
+		crand.Read(b)
+		return []interface{}{limit.Rate, limit.Period.Microseconds(), n, hex.EncodeToString(b)}
+	}}
+}
+
+// NewTokenBucket returns Limiter of the token bucket,
+// which holds Burst tokens and refills Rate tokens per Period, each request takes one token.
+// It stores a hash of tokens and the last update time per key.
+func NewTokenBucket(p *redisgo.Pool, limit Limit) *Limiter {
+	limit.check()
+	return &Limiter{p: p, limit: limit, capacity: limit.burst(), script: tokenBucketScript, args: func(n int64) []interface{} {
+		return []interface{}{limit.burst(), limit.interval(), n}
+	}}
+}
+
+// Limit returns the Limit of l
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow is shorthand for AllowN(ctx, key, 1)
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN reports whether n requests of key are allowed, and takes them if allowed.
+// ctx is passed to Pool.Get
+func (l *Limiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	var res Result
+	conn, err := l.p.Get(ctx)
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	args := append([]interface{}{key}, l.args(n)...)
+	reply, err := l.script.Do(conn.Conn, args...)
+	if err != nil {
+		return res, err
+	}
+	defer reply.Free()
+	var allowed, retry, reset int64
+	if err := reply.Scan(&allowed, &res.Remaining, &retry, &reset); err != nil {
+		return res, err
+	}
+	res.Allowed = allowed == 1
+	res.RetryAfter = microseconds(retry)
+	res.ResetAfter = microseconds(reset)
+	return res, nil
+}
+
+func microseconds(us int64) time.Duration {
+	if us < 0 {
+		return -1
+	}
+	return time.Duration(us) * time.Microsecond
+}
+
+// all scripts return {allowed, remaining, retry after, reset after} with times in microseconds
+
+// gcraScript stores the theoretical arrival time (TAT) of the next request.
+// ARGV: burst, emission interval, cost
+var gcraScript = redisgo.NewScript(1, `
+local burst = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call("GET", KEYS[1])) or now
+if tat < now then
+	tat = now
+end
+local newtat = tat + interval * cost
+local diff = now - (newtat - interval * burst)
+if diff < 0 then
+	local retry = -1
+	if cost <= burst then
+		retry = math.ceil(-diff)
+	end
+	local remaining = math.max(math.floor((now - tat) / interval + burst), 0)
+	return {0, remaining, retry, math.ceil(tat - now)}
+end
+local reset = math.ceil(newtat - now)
+if reset > 0 then
+	redis.call("SET", KEYS[1], string.format("%.17g", newtat), "PX", math.ceil(reset / 1000))
+end
+return {1, math.floor(diff / interval), 0, reset}`)
+
+// slidingWindowScript stores a sorted set of requests scored by time.
+// ARGV: limit, window, cost, unique id of the call
+var slidingWindowScript = redisgo.NewScript(1, `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", string.format("%d", now - window))
+local count = redis.call("ZCARD", KEYS[1])
+if count + cost > limit then
+	local retry = -1
+	if cost <= limit then
+		local i = count + cost - limit - 1
+		local e = redis.call("ZRANGE", KEYS[1], i, i, "WITHSCORES")
+		retry = tonumber(e[2]) + window - now
+	end
+	local reset = 0
+	local last = redis.call("ZRANGE", KEYS[1], -1, -1, "WITHSCORES")
+	if last[2] then
+		reset = tonumber(last[2]) + window - now
+	end
+	return {0, math.max(limit - count, 0), retry, reset}
+end
+for i = 1, cost do
+	redis.call("ZADD", KEYS[1], string.format("%d", now), ARGV[4] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], math.ceil(window / 1000))
+return {1, limit - count - cost, 0, window}`)
+
+// tokenBucketScript stores a hash of the tokens and the time they were counted.
+// ARGV: capacity, refill interval of a token, cost
+var tokenBucketScript = redisgo.NewScript(1, `
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local b = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(b[1]) or capacity
+local ts = tonumber(b[2]) or now
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) / interval)
+if tokens < cost then
+	local retry = -1
+	if cost <= capacity then
+		retry = math.ceil((cost - tokens) * interval)
+	end
+	return {0, math.floor(tokens), retry, math.ceil((capacity - tokens) * interval)}
+end
+tokens = tokens - cost
+local reset = math.ceil((capacity - tokens) * interval)
+redis.call("HSET", KEYS[1], "tokens", string.format("%.17g", tokens), "ts", string.format("%d", now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(reset / 1000) + 1)
+return {1, math.floor(tokens), 0, reset}`)
@@ -0,0 +1,228 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+func TestLimiter(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	p := redisgo.NewPool(m.Dial)
+	ctx := context.Background()
+
+	l := NewGCRA(p, PerSecond(10))
+	m.Expect("EVALSHA", gcraScript.Hash(), 1, "k", 10, 100000, 1).Return([]int64{1, 9, 0, 100000})
+	res, err := l.Allow(ctx, "k")
+	if err != nil || res != (Result{Allowed: true, Remaining: 9, ResetAfter: 100 * time.Millisecond}) {
+		t.Fatal(res, err)
+	}
+
+	l = NewTokenBucket(p, Limit{Rate: 1, Period: time.Minute, Burst: 5})
+	m.Expect("EVALSHA", tokenBucketScript.Hash(), 1, "k", 5, 6e7, 6).Return([]int64{0, 5, -1, 0})
+	res, err = l.AllowN(ctx, "k", 6)
+	if err != nil || res != (Result{RetryAfter: -1, Remaining: 5}) {
+		t.Fatal(res, err)
+	}
+
+	l = NewSlidingWindow(p, PerMinute(100))
+	m.ExpectFunc("EVALSHA", func(args [][]byte) bool {
+		return string(args[0]) == slidingWindowScript.Hash() && string(args[2]) == "k" &&
+			string(args[3]) == "100" && string(args[4]) == "60000000" && string(args[5]) == "1" && len(args[6]) == 16
+	}).Return([]int64{0, 0, 1500, 59000000})
+	res, err = l.Allow(ctx, "k")
+	if err != nil || res != (Result{RetryAfter: 1500 * time.Microsecond, ResetAfter: 59 * time.Second}) {
+		t.Fatal(res, err)
+	}
+
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidLimit(t *testing.T) {
+	p := redisgo.NewPool(nil)
+	for _, limit := range []Limit{{Rate: 0, Period: time.Second}, {Rate: -1, Period: time.Second}, {Rate: 1, Period: time.Microsecond}} {
+		for _, newfunc := range []func(*redisgo.Pool, Limit) *Limiter{NewGCRA, NewSlidingWindow, NewTokenBucket} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Fatal(limit, "expect panic")
+					}
+				}()
+				newfunc(p, limit)
+			}()
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	l := NewGCRA(redisgo.NewPool(m.Dial), Limit{Rate: 2, Period: time.Second, Burst: 5})
+	h := Middleware(l, KeyByRemoteAddr("ip:"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	m.Expect("EVALSHA", gcraScript.Hash(), 1, "ip:10.0.0.1", 5, 500000, 1).Return([]int64{1, 4, 0, 500000})
+	m.Expect("EVALSHA", gcraScript.Hash(), 1, "ip:10.0.0.1", 5, 500000, 1).Return([]int64{0, 0, 300000, 1000000})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 || w.Header().Get("RateLimit-Remaining") != "4" || w.Header().Get("RateLimit-Limit") != "5" {
+		t.Fatal(w.Code, w.Header())
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" || w.Header().Get("RateLimit-Reset") != "1" {
+		t.Fatal(w.Code, w.Header())
+	}
+
+	// fails open by default
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatal(w.Code)
+	}
+
+	var gotErr error
+	h = Middleware(l, KeyByRemoteAddr("ip:"), WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		gotErr = err
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))(http.NotFoundHandler())
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable || gotErr == nil {
+		t.Fatal(w.Code, gotErr)
+	}
+}
+
+// redisTest runs fn with a limiter created by newfunc and a new key which is deleted after the test
+func redisTest(t *testing.T, newfunc func(p *redisgo.Pool, limit Limit) *Limiter, limit Limit,
+	fn func(allow func(n int64) Result, pttl func() int64)) {
+	p := redistest.LocalPool(t)
+	ctx := context.Background()
+	key := "redisgo:test:ratelimit:" + t.Name() + ":" + time.Now().Format(time.RFC3339Nano)
+	conn, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	defer conn.DoNoReply("DEL", key)
+
+	l := newfunc(p, limit)
+	fn(func(n int64) Result {
+		t.Helper()
+		res, err := l.AllowN(ctx, key, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}, func() int64 {
+		t.Helper()
+		ms, err := conn.DoInteger("PTTL", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ms
+	})
+}
+
+func TestGCRARedis(t *testing.T) {
+	limit := Limit{Rate: 5, Period: 500 * time.Millisecond, Burst: 3} // a request per 100ms
+	redisTest(t, NewGCRA, limit, func(allow func(n int64) Result, pttl func() int64) {
+		for i := int64(2); i >= 0; i-- {
+			if res := allow(1); !res.Allowed || res.Remaining != i {
+				t.Fatal(i, res)
+			}
+		}
+		if ms := pttl(); ms <= 0 || ms > 300 {
+			t.Fatal("pttl", ms)
+		}
+		res := allow(1)
+		if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 100*time.Millisecond {
+			t.Fatal(res)
+		}
+		if res := allow(4); res.Allowed || res.RetryAfter != -1 {
+			t.Fatal(res)
+		}
+
+		// refills a request per interval, and the burst after Period
+		time.Sleep(res.RetryAfter)
+		if res = allow(1); !res.Allowed || res.Remaining != 0 {
+			t.Fatal(res)
+		}
+		time.Sleep(limit.Period)
+		if res = allow(3); !res.Allowed || res.Remaining != 0 {
+			t.Fatal(res)
+		}
+	})
+}
+
+func TestSlidingWindowRedis(t *testing.T) {
+	limit := Limit{Rate: 3, Period: 300 * time.Millisecond}
+	redisTest(t, NewSlidingWindow, limit, func(allow func(n int64) Result, pttl func() int64) {
+		if res := allow(2); !res.Allowed || res.Remaining != 1 {
+			t.Fatal(res)
+		}
+		time.Sleep(limit.Period / 2)
+		if res := allow(1); !res.Allowed || res.Remaining != 0 {
+			t.Fatal(res)
+		}
+		if ms := pttl(); ms <= 0 || ms > 300 {
+			t.Fatal("pttl", ms)
+		}
+		res := allow(1)
+		if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > limit.Period/2 {
+			t.Fatal(res)
+		}
+		if res := allow(4); res.Allowed || res.RetryAfter != -1 {
+			t.Fatal(res)
+		}
+
+		// only the 2 requests of the first half leave the window at its boundary
+		time.Sleep(res.RetryAfter)
+		if res = allow(2); !res.Allowed || res.Remaining != 0 {
+			t.Fatal(res)
+		}
+		if res = allow(1); res.Allowed {
+			t.Fatal(res)
+		}
+	})
+}
+
+func TestTokenBucketRedis(t *testing.T) {
+	limit := Limit{Rate: 5, Period: 500 * time.Millisecond, Burst: 3} // a token per 100ms
+	redisTest(t, NewTokenBucket, limit, func(allow func(n int64) Result, pttl func() int64) {
+		if res := allow(3); !res.Allowed || res.Remaining != 0 {
+			t.Fatal(res)
+		}
+		if ms := pttl(); ms <= 0 || ms > 302 {
+			t.Fatal("pttl", ms)
+		}
+		res := allow(1)
+		if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > 100*time.Millisecond {
+			t.Fatal(res)
+		}
+		if res := allow(4); res.Allowed || res.RetryAfter != -1 {
+			t.Fatal(res)
+		}
+
+		// refills no more than Burst tokens
+		time.Sleep(2 * limit.Period)
+		if res = allow(3); !res.Allowed || res.Remaining != 0 {
+			t.Fatal(res)
+		}
+		if res = allow(1); res.Allowed {
+			t.Fatal(res)
+		}
+	})
+}
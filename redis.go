@@ -1,18 +1,21 @@
 package redisgo
 
 import (
-	"bufio"
+	"context"
+	"errors"
+	"io"
 	"net"
-	"strconv"
 	"time"
-	"unsafe"
+
+	"github.com/xiaost/redisgo/resp"
 )
 
 // Conn represents a redis client
 type Conn struct {
 	conn net.Conn
-	br   *reader
-	bw   *bufio.Writer
+	rd   *limitedReader // reader of dec
+	dec  *resp.Decoder
+	enc  *resp.Encoder
 
 	err    error
 	closed bool
@@ -20,6 +23,9 @@ type Conn struct {
 
 	rtimeout time.Duration
 	wtimeout time.Duration
+
+	p          *pipeline // for DoAsync
+	maxpending int
 }
 
 // NewConn creates Conn
@@ -30,10 +36,15 @@ func NewConn(conn net.Conn, ops ...Option) *Conn {
 	}
 	var r Conn
 	r.conn = conn
-	r.br = newReader(conn, o.rbuf)
-	r.bw = bufio.NewWriterSize(conn, o.wbuf)
+	r.rd = &limitedReader{r: conn, n: -1}
+	r.dec = resp.NewDecoderSize(r.rd, o.rbuf)
+	r.dec.SetMaxBulkLen(o.maxbulklen)
+	r.dec.SetMaxArrayLen(o.maxarraylen)
+	r.dec.SetMaxDepth(o.maxdepth)
+	r.enc = resp.NewEncoderSize(conn, o.wbuf)
 	r.rtimeout = o.rtimeout
 	r.wtimeout = o.wtimeout
+	r.maxpending = o.maxpending
 	return &r
 }
 
@@ -56,10 +67,13 @@ func (c *Conn) SetTimeout(t time.Duration) {
 // Do sends command to redis and recv reply.
 // Reply.Free() SHOULD be called when no longer used
 func (c *Conn) Do(cmd string, args ...interface{}) (*Reply, error) {
+	if c.p != nil {
+		return c.DoAsync(cmd, args...).Wait(context.Background())
+	}
 	if err := c.Send(cmd, args...); err != nil {
 		return nil, err
 	}
-	reply := replyPool.Get().(*Reply)
+	reply := resp.NewReply()
 	if err := c.Recv(reply); err != nil {
 		reply.Free()
 		return nil, err
@@ -69,55 +83,131 @@ func (c *Conn) Do(cmd string, args ...interface{}) (*Reply, error) {
 
 // Send sends command to redis
 func (c *Conn) Send(cmd string, args ...interface{}) (err error) {
+	if c.p != nil {
+		return errAsync
+	}
 	if err = c.Err(); err != nil {
 		return
 	}
-	for _, a := range args {
-		if !validarg(a) {
-			return c.seterr(errInvalidArgType)
-		}
-	}
-	c.pd++
 	if c.wtimeout > 0 {
 		c.conn.SetWriteDeadline(time.Now().Add(c.wtimeout))
 	}
-	cc := commandPool.Get().(*command)
-	err = c.seterr(cc.Reset(cmd).Args(args...).Dump(c.bw))
-	commandPool.Put(cc)
-	return
+	if err = c.enc.WriteCommand(cmd, args...); err == nil {
+		c.pd++
+	} else if errors.Is(err, ErrInvalidArgType) {
+		return err // nothing written, the conn is still usable
+	}
+	return c.seterr(err)
 }
 
 // Flush writes any buffered data to redis
 func (c *Conn) Flush() error {
-	if c.bw.Buffered() == 0 {
+	if c.p != nil || c.enc.Buffered() == 0 {
 		return nil
 	}
 	if c.wtimeout > 0 {
 		c.conn.SetWriteDeadline(time.Now().Add(c.wtimeout))
 	}
-	return c.bw.Flush()
+	return c.enc.Flush()
+}
+
+// DoTimeout is like Do but the read timeout of the reply is timeout instead of the one of Conn,
+// for blocking commands like BLPOP or XREADGROUP with BLOCK which take longer than usual.
+// The reply is waited without timeout if timeout <= 0. It's not allowed after DoAsync.
+func (c *Conn) DoTimeout(timeout time.Duration, cmd string, args ...interface{}) (*Reply, error) {
+	if err := c.Send(cmd, args...); err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		c.conn.SetReadDeadline(time.Time{}) // the one of the last Recv must not fire
+	}
+	reply := resp.NewReply()
+	err := c.recv(reply, timeout)
+	if c.rtimeout <= 0 && err == nil {
+		c.conn.SetReadDeadline(time.Time{}) // Recv doesn't reset it without rtimeout
+	}
+	if err != nil {
+		reply.Free()
+		return nil, err
+	}
+	return reply, nil
 }
 
 // Recv receives reply from redis
 func (c *Conn) Recv(reply *Reply) (err error) {
+	return c.recv(reply, c.rtimeout)
+}
+
+func (c *Conn) recv(reply *Reply, rtimeout time.Duration) (err error) {
 	reply.Reset()
+	if c.p != nil {
+		return errAsync
+	}
 	if err = c.Err(); err != nil {
 		return
 	}
-	if c.bw.Buffered() > 0 {
+	if c.enc.Buffered() > 0 {
 		if c.wtimeout > 0 {
 			c.conn.SetWriteDeadline(time.Now().Add(c.wtimeout))
 		}
-		err = c.seterr(c.bw.Flush())
+		err = c.seterr(c.enc.Flush())
 		if err != nil {
 			return
 		}
 	}
 	c.pd--
-	if c.rtimeout > 0 {
-		c.conn.SetReadDeadline(time.Now().Add(c.rtimeout))
+	if rtimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(rtimeout))
 	}
-	return c.seterr(c.read(reply))
+	return c.seterr(c.dec.Decode(reply))
+}
+
+// drain receives and discards pending replies within timeout and maxbytes
+func (c *Conn) drain(timeout time.Duration, maxbytes int64) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	c.conn.SetWriteDeadline(deadline)
+	if err := c.seterr(c.enc.Flush()); err != nil {
+		return err
+	}
+	c.conn.SetReadDeadline(deadline)
+	reply := resp.NewReply()
+	defer reply.Free()
+	// limits bytes read from conn, so that a large reply is not read in full before checking
+	c.rd.n = maxbytes
+	defer func() { c.rd.n = -1 }()
+	for c.pd > 0 {
+		c.pd--
+		if err := c.seterr(c.dec.Decode(reply)); err != nil {
+			return err
+		}
+	}
+	c.conn.SetDeadline(time.Time{})
+	return nil
+}
+
+// limitedReader is like io.LimitedReader but returns errDrainLimit after n bytes,
+// it's not limited if n < 0
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return l.r.Read(p)
+	}
+	if l.n == 0 {
+		return 0, errDrainLimit
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
 }
 
 // Conn returns the underlying net.Conn
@@ -127,6 +217,10 @@ func (c *Conn) Conn() net.Conn {
 
 // Close closes the underlying connection
 func (c *Conn) Close() error {
+	if c.p != nil {
+		// c.err and c.closed are not used after DoAsync, since Err may be called concurrently like by MuxConn
+		return c.p.close()
+	}
 	c.Flush()
 	if c.closed {
 		return errClosed
@@ -141,6 +235,9 @@ func (c *Conn) Close() error {
 // Err returns last fatal err.
 // if not nil, caller must not reuse the instance
 func (c *Conn) Err() error {
+	if c.p != nil {
+		return c.p.Err()
+	}
 	return c.err
 }
 
@@ -160,72 +257,6 @@ func (c *Conn) seterr(err error) error {
 	return err
 }
 
-func (c *Conn) read(r *Reply) (err error) {
-	var b []byte
-	b, err = c.br.Readline()
-	if err != nil {
-		return
-	}
-	b = b[:len(b)-2] // remove CRLF
-	if len(b) == 0 {
-		err = errProtocol
-		return
-	}
-	t := b[0]
-	b = b[1:]
-	var n int // for strlen or arraylen
-	switch t {
-	case '+': // Simple Strings
-		r.b = b
-		r.t = typeSString
-	case '$': // Bulk Strings
-		n, err = strconv.Atoi(ss(b))
-		if err != nil {
-			return
-		}
-		if n == -1 {
-			r.t = typeNil
-			return
-		}
-		r.b, err = c.br.Read(n + 2)
-		if err != nil {
-			return
-		}
-		r.b = r.b[:n:n]
-		r.t = typeBString
-	case ':': // Integers
-		r.i, err = strconv.ParseInt(ss(b), 10, 64)
-		if err == nil {
-			r.t = typeInteger
-		}
-	case '*': // Arrays
-		n, err = strconv.Atoi(ss(b))
-		if err != nil {
-			return
-		}
-		if n == -1 {
-			r.t = typeNilArray
-			return
-		}
-		if r.array == nil {
-			r.array = make([]Reply, 0, n)
-		}
-		for i := 0; i < n; i++ {
-			r.array = append(r.array, Reply{})
-			if err = c.read(&r.array[i]); err != nil {
-				return
-			}
-		}
-		r.t = typeArray
-	case '-': // Errors
-		r.t = typeError
-		r.err = *(*RedisErr)(unsafe.Pointer(&b))
-	default:
-		err = errProtocol
-	}
-	return
-}
-
 // DoNoReply wraps Do() and Reply.Err()
 func (c *Conn) DoNoReply(cmd string, args ...interface{}) error {
 	reply, err := c.Do(cmd, args...)
@@ -281,3 +312,46 @@ func (c *Conn) DoInteger(cmd string, args ...interface{}) (int64, error) {
 	defer reply.Free()
 	return reply.Integer()
 }
+
+// DoFloat64 wraps Do() and reply.Float64()
+func (c *Conn) DoFloat64(cmd string, args ...interface{}) (float64, error) {
+	reply, err := c.Do(cmd, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer reply.Free()
+	return reply.Float64()
+}
+
+// DoString wraps Do() and reply.Str()
+func (c *Conn) DoString(cmd string, args ...interface{}) (string, error) {
+	reply, err := c.Do(cmd, args...)
+	if err != nil {
+		return "", err
+	}
+	defer reply.Free()
+	return reply.Str()
+}
+
+// DoStringMap wraps Do() and reply.StringMap()
+func (c *Conn) DoStringMap(cmd string, args ...interface{}) (map[string]string, error) {
+	reply, err := c.Do(cmd, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Free()
+	return reply.StringMap()
+}
+
+// DoAs wraps Do() and Reply.Unmarshal(), it decodes the reply into a value of T,
+// like DoAs[[]string] for SMEMBERS or DoAs[map[string]int64] for HGETALL.
+func DoAs[T any](c *Conn, cmd string, args ...interface{}) (T, error) {
+	var v T
+	reply, err := c.Do(cmd, args...)
+	if err != nil {
+		return v, err
+	}
+	defer reply.Free()
+	err = reply.Unmarshal(&v)
+	return v, err
+}
@@ -71,6 +71,68 @@ func TestRedis(t *testing.T) {
 	cli.Do("MGET", "x", "y")
 }
 
+func TestConnRead(t *testing.T) {
+	read := func(s string, ops ...Option) (*Reply, error) {
+		cli := NewConn(&FakeConn{reply: []byte(s)}, ops...)
+		reply := NewReply()
+		return reply, cli.Recv(reply)
+	}
+	reply, err := read("*3\r\n*2\r\n:1\r\n$1\r\na\r\n*0\r\n$-1\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aa, _ := reply.Array()
+	if len(aa) != 3 {
+		t.Fatal(len(aa))
+	}
+	a0, _ := aa[0].Array()
+	if i, _ := a0[0].Integer(); i != 1 {
+		t.Fatal(i)
+	}
+	if b, _ := a0[1].Bytes(); string(b) != "a" {
+		t.Fatal(string(b))
+	}
+	if a1, err := aa[1].Array(); err != nil || len(a1) != 0 {
+		t.Fatal(a1, err)
+	}
+	if !aa[2].IsNil() {
+		t.Fatal("not nil")
+	}
+	reply.Free()
+
+	for _, tc := range []struct {
+		s   string
+		ops []Option
+	}{
+		{"$2000000000\r\n", nil},
+		{"*2000000000\r\n", []Option{WithMaxArrayLen(100)}},
+		{"$-2\r\n", nil},
+		{"*-2\r\n", nil},
+		{"$1\r\nab\r\n", nil},
+		{"*1\r\n*1\r\n*1\r\n:1\r\n", []Option{WithMaxDepth(2)}},
+		{"?\r\n", nil},
+	} {
+		_, err := read(tc.s, tc.ops...)
+		if _, ok := err.(*ProtocolError); !ok {
+			t.Fatal(tc.s, err)
+		}
+		t.Log(err)
+	}
+}
+
+func TestSendInvalidArg(t *testing.T) {
+	cli := NewConn(&FakeConn{reply: []byte("+OK\r\n")})
+	if err := cli.Send("SET", "k", struct{}{}); err != ErrInvalidArgType {
+		t.Fatal(err)
+	}
+	if cli.Err() != nil || cli.pd != 0 {
+		t.Fatal(cli.Err(), cli.pd)
+	}
+	if err := cli.DoNoReply("SET", "k", true); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func BenchmarkCmdSetRedisgo(b *testing.B) {
 	b.ReportAllocs()
 	var conn = net.Conn(&FakeConn{reply: []byte("+OK\r\n")})
@@ -0,0 +1,300 @@
+package redistest
+
+import (
+	"sort"
+	"time"
+)
+
+// entry represents a key, v is one of:
+// string, *list, hash, set, *zset
+type entry struct {
+	v        interface{}
+	expireAt time.Time // zero if no ttl
+}
+
+// value returns e.v, nil is returned if e is nil
+func (e *entry) value() interface{} {
+	if e == nil {
+		return nil
+	}
+	return e.v
+}
+
+type (
+	list struct{ a []string }
+	hash map[string]string
+	set  map[string]struct{}
+)
+
+func typename(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case *list:
+		return "list"
+	case hash:
+		return "hash"
+	case set:
+		return "set"
+	case *zset:
+		return "zset"
+	}
+	return "none"
+}
+
+type db struct {
+	m        map[string]*entry
+	versions map[string]uint64 // for WATCH
+	version  uint64
+}
+
+func newdb() *db {
+	return &db{m: make(map[string]*entry), versions: make(map[string]uint64)}
+}
+
+// get returns the entry of key, expired keys are deleted
+func (db *db) get(now time.Time, key string) *entry {
+	e := db.m[key]
+	if e == nil {
+		return nil
+	}
+	if !e.expireAt.IsZero() && !now.Before(e.expireAt) {
+		db.del(key)
+		return nil
+	}
+	return e
+}
+
+// set sets the value of key, the ttl of key is removed
+func (db *db) set(key string, v interface{}) {
+	db.m[key] = &entry{v: v}
+	db.touch(key)
+}
+
+// del deletes key and returns true if it exists
+func (db *db) del(key string) bool {
+	if _, ok := db.m[key]; !ok {
+		return false
+	}
+	delete(db.m, key)
+	db.touch(key)
+	return true
+}
+
+// touch marks key as modified for WATCH
+func (db *db) touch(key string) {
+	db.version++
+	db.versions[key] = db.version
+}
+
+func (db *db) flush() {
+	for k := range db.m {
+		db.touch(k)
+	}
+	db.m = make(map[string]*entry)
+}
+
+// keys returns keys matching pattern in order
+func (db *db) keys(now time.Time, pattern string) []string {
+	ret := make([]string, 0, len(db.m))
+	for k := range db.m {
+		if db.get(now, k) == nil {
+			continue
+		}
+		if match(pattern, k) {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// getstring returns the string value of key, ok is false if it's the wrong type
+func (x *cmdctx) getstring(key string) (v string, exists, ok bool) {
+	e := x.db.get(x.now, key)
+	if e == nil {
+		return "", false, true
+	}
+	v, ok = e.v.(string)
+	if !ok {
+		x.err(errWrongType)
+	}
+	return v, true, ok
+}
+
+func (x *cmdctx) getlist(key string, create bool) (*list, bool) {
+	e := x.db.get(x.now, key)
+	if e == nil {
+		if !create {
+			return nil, true
+		}
+		l := &list{}
+		x.db.set(key, l)
+		return l, true
+	}
+	l, ok := e.v.(*list)
+	if !ok {
+		x.err(errWrongType)
+	}
+	return l, ok
+}
+
+func (x *cmdctx) gethash(key string, create bool) (hash, bool) {
+	e := x.db.get(x.now, key)
+	if e == nil {
+		if !create {
+			return nil, true
+		}
+		h := make(hash)
+		x.db.set(key, h)
+		return h, true
+	}
+	h, ok := e.v.(hash)
+	if !ok {
+		x.err(errWrongType)
+	}
+	return h, ok
+}
+
+func (x *cmdctx) getset(key string, create bool) (set, bool) {
+	e := x.db.get(x.now, key)
+	if e == nil {
+		if !create {
+			return nil, true
+		}
+		st := make(set)
+		x.db.set(key, st)
+		return st, true
+	}
+	st, ok := e.v.(set)
+	if !ok {
+		x.err(errWrongType)
+	}
+	return st, ok
+}
+
+func (x *cmdctx) getzset(key string, create bool) (*zset, bool) {
+	e := x.db.get(x.now, key)
+	if e == nil {
+		if !create {
+			return nil, true
+		}
+		z := newzset()
+		x.db.set(key, z)
+		return z, true
+	}
+	z, ok := e.v.(*zset)
+	if !ok {
+		x.err(errWrongType)
+	}
+	return z, ok
+}
+
+// cleanup deletes key if its value is an empty collection,
+// and marks key as modified
+func (x *cmdctx) cleanup(key string) {
+	e := x.db.m[key]
+	if e == nil {
+		return
+	}
+	empty := false
+	switch v := e.v.(type) {
+	case *list:
+		empty = len(v.a) == 0
+	case hash:
+		empty = len(v) == 0
+	case set:
+		empty = len(v) == 0
+	case *zset:
+		empty = v.len() == 0
+	}
+	if empty {
+		x.db.del(key)
+	} else {
+		x.db.touch(key)
+	}
+}
+
+// match reports whether s matches the glob-style pattern of redis,
+// which supports *, ?, [abc], [^a-z] and backslash for escaping.
+func match(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if match(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			end := 1
+			for end < len(pattern) && pattern[end] != ']' {
+				if pattern[end] == '\\' && end+1 < len(pattern) {
+					end++
+				}
+				end++
+			}
+			if end == len(pattern) { // no closing ']', matches '[' literally
+				if s[0] != '[' {
+					return false
+				}
+				break
+			}
+			if !matchclass(pattern[1:end], s[0]) {
+				return false
+			}
+			pattern = pattern[end:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+	return len(s) == 0
+}
+
+// matchclass reports whether c matches the class like "abc" or "^a-z"
+func matchclass(class string, c byte) bool {
+	not := len(class) > 0 && class[0] == '^'
+	if not {
+		class = class[1:]
+	}
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if class[i] == '\\' && i+1 < len(class) {
+			i++
+			matched = matched || class[i] == c
+		} else if i+2 < len(class) && class[i+1] == '-' {
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (c >= lo && c <= hi)
+			i += 2
+		} else {
+			matched = matched || class[i] == c
+		}
+	}
+	return matched != not
+}
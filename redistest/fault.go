@@ -0,0 +1,269 @@
+package redistest
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/xiaost/redisgo"
+)
+
+// Faults configures the faults injected by FaultConn, zero values disable them.
+// Offsets and counts are in bytes of the whole connection, starting from 0.
+type Faults struct {
+	// Latency is added before every Read and Write
+	Latency time.Duration
+
+	// ReadSize limits the bytes returned by a Read, 1 splits replies at every byte
+	ReadSize int
+
+	// SplitAt splits reads at the offsets, like in the middle of a CRLF or a bulk string
+	SplitAt []int64
+
+	// ResetAfterRead resets the connection after n bytes read
+	ResetAfterRead int64
+
+	// ResetAfterWrite resets the connection after n bytes written
+	ResetAfterWrite int64
+
+	// StallAfter stalls reads after n bytes read until the read deadline or Resume
+	StallAfter int64
+
+	// Corrupt replaces the bytes read at the offsets
+	Corrupt map[int64]byte
+}
+
+// FaultConn is a net.Conn which injects faults to the wrapped conn
+type FaultConn struct {
+	net.Conn
+	f Faults
+
+	mu        sync.Mutex
+	nread     int64
+	nwritten  int64
+	reset     bool
+	closed    bool
+	stalled   bool
+	rdeadline time.Time
+	wake      chan struct{} // closed and renewed when stall state or read deadline changes
+}
+
+// NewFaultConn returns a FaultConn which wraps conn
+func NewFaultConn(conn net.Conn, f Faults) *FaultConn {
+	return &FaultConn{Conn: conn, f: f, wake: make(chan struct{})}
+}
+
+// DialWithFaults wraps dial and injects faults to the conns it returns.
+// The underlying net.Conn of the dialed conn is wrapped and passed to redisgo.NewConn with ops,
+// so ops should be the same as the ones used by dial.
+func DialWithFaults(dial redisgo.DialFunc, f Faults, ops ...redisgo.Option) redisgo.DialFunc {
+	return func(ctx context.Context) (*redisgo.Conn, error) {
+		c, err := dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return redisgo.NewConn(NewFaultConn(c.Conn(), f), ops...), nil
+	}
+}
+
+// errReset returns the error of a connection reset by peer like the one of a tcp conn
+func errReset(op string) error {
+	return &net.OpError{Op: op, Net: "tcp", Err: os.NewSyscallError(op, syscall.ECONNRESET)}
+}
+
+// notify wakes up stalled reads, it must be called with c.mu held
+func (c *FaultConn) notify() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// Stall stalls reads until the read deadline exceeded or Resume is called
+func (c *FaultConn) Stall() {
+	c.mu.Lock()
+	c.stalled = true
+	c.notify()
+	c.mu.Unlock()
+}
+
+// Resume resumes stalled reads
+func (c *FaultConn) Resume() {
+	c.mu.Lock()
+	c.stalled = false
+	c.f.StallAfter = 0
+	c.notify()
+	c.mu.Unlock()
+}
+
+// Reset closes the underlying conn, then reads and writes return connection reset errors
+func (c *FaultConn) Reset() {
+	c.mu.Lock()
+	c.reset = true
+	c.notify()
+	c.mu.Unlock()
+	c.Conn.Close()
+}
+
+// Closed returns true if Close has been called
+func (c *FaultConn) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// BytesRead returns the number of bytes read
+func (c *FaultConn) BytesRead() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nread
+}
+
+// BytesWritten returns the number of bytes written
+func (c *FaultConn) BytesWritten() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nwritten
+}
+
+// waitstall blocks while reads are stalled, it must be called with c.mu held
+func (c *FaultConn) waitstall() error {
+	for !c.reset && !c.closed &&
+		(c.stalled || (c.f.StallAfter > 0 && c.nread >= c.f.StallAfter)) {
+		wake := c.wake
+		var t *time.Timer
+		var timeout <-chan time.Time
+		if !c.rdeadline.IsZero() {
+			d := time.Until(c.rdeadline)
+			if d <= 0 {
+				return os.ErrDeadlineExceeded
+			}
+			t = time.NewTimer(d)
+			timeout = t.C
+		}
+		c.mu.Unlock()
+		select {
+		case <-wake:
+		case <-timeout:
+		}
+		if t != nil {
+			t.Stop()
+		}
+		c.mu.Lock()
+	}
+	return nil
+}
+
+// readlimit returns the max bytes of the next Read, it must be called with c.mu held
+func (c *FaultConn) readlimit(n int) int {
+	if c.f.ReadSize > 0 && n > c.f.ReadSize {
+		n = c.f.ReadSize
+	}
+	for _, off := range c.f.SplitAt {
+		if off > c.nread && off-c.nread < int64(n) {
+			n = int(off - c.nread)
+		}
+	}
+	if c.f.StallAfter > c.nread && c.f.StallAfter-c.nread < int64(n) {
+		n = int(c.f.StallAfter - c.nread)
+	}
+	if c.f.ResetAfterRead > c.nread && c.f.ResetAfterRead-c.nread < int64(n) {
+		n = int(c.f.ResetAfterRead - c.nread)
+	}
+	return n
+}
+
+// Read reads data from the wrapped conn with faults injected
+func (c *FaultConn) Read(b []byte) (int, error) {
+	if c.f.Latency > 0 {
+		time.Sleep(c.f.Latency)
+	}
+	c.mu.Lock()
+	if err := c.waitstall(); err != nil {
+		c.mu.Unlock()
+		return 0, err
+	}
+	if c.reset || (c.f.ResetAfterRead > 0 && c.nread >= c.f.ResetAfterRead) {
+		c.mu.Unlock()
+		c.Reset()
+		return 0, errReset("read")
+	}
+	if len(b) > 0 {
+		b = b[:c.readlimit(len(b))]
+	}
+	off := c.nread
+	c.mu.Unlock()
+
+	n, err := c.Conn.Read(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := 0; i < n; i++ {
+		if v, ok := c.f.Corrupt[off+int64(i)]; ok {
+			b[i] = v
+		}
+	}
+	c.nread += int64(n)
+	if err != nil && c.reset {
+		err = errReset("read")
+	}
+	return n, err
+}
+
+// Write writes data to the wrapped conn with faults injected
+func (c *FaultConn) Write(b []byte) (int, error) {
+	if c.f.Latency > 0 {
+		time.Sleep(c.f.Latency)
+	}
+	c.mu.Lock()
+	if c.reset {
+		c.mu.Unlock()
+		return 0, errReset("write")
+	}
+	reset := false
+	if c.f.ResetAfterWrite > 0 && c.nwritten+int64(len(b)) > c.f.ResetAfterWrite {
+		b = b[:c.f.ResetAfterWrite-c.nwritten]
+		reset = true
+	}
+	c.mu.Unlock()
+
+	n, err := c.Conn.Write(b)
+
+	c.mu.Lock()
+	c.nwritten += int64(n)
+	c.mu.Unlock()
+	if err == nil && reset {
+		c.Reset()
+		err = errReset("write")
+	}
+	return n, err
+}
+
+// Close closes the wrapped conn
+func (c *FaultConn) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.notify()
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// SetDeadline sets the read and write deadlines of the wrapped conn
+func (c *FaultConn) SetDeadline(t time.Time) error {
+	c.setrdeadline(t)
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the wrapped conn, it also applies to stalled reads
+func (c *FaultConn) SetReadDeadline(t time.Time) error {
+	c.setrdeadline(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *FaultConn) setrdeadline(t time.Time) {
+	c.mu.Lock()
+	c.rdeadline = t
+	c.notify()
+	c.mu.Unlock()
+}
@@ -0,0 +1,122 @@
+package redistest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+)
+
+func TestFaultConnSplitReads(t *testing.T) {
+	s := NewUnstartedServer()
+	defer s.Close()
+	big := strings.Repeat("x", 5000)
+	for _, f := range []Faults{
+		{ReadSize: 1},
+		{ReadSize: 7},
+		{SplitAt: []int64{4, 6, 9, 11}}, // "+OK\r|\n$|1\r\n|1\r|\n"
+	} {
+		fc := NewFaultConn(s.Pipe(), f)
+		conn := redisgo.NewConn(fc, redisgo.WithReadBuffer(16))
+		expect(t, do(t, conn, "SET", "k", "1"), "OK")
+		expect(t, do(t, conn, "GET", "k"), "1")
+		expect(t, do(t, conn, "SET", "big", big), "OK")
+		expect(t, do(t, conn, "GET", "big"), big)
+		expect(t, do(t, conn, "RPUSH", "l", "a", "", big), int64(3))
+		expect(t, do(t, conn, "LRANGE", "l", 0, -1), a("a", "", big))
+		expect(t, do(t, conn, "GET", "none"), nil)
+		conn.Close()
+		s.FlushAll()
+	}
+}
+
+func TestFaultConnErrors(t *testing.T) {
+	s := NewUnstartedServer()
+	defer s.Close()
+
+	isreset := func(err error) bool {
+		return errors.Is(err, syscall.ECONNRESET)
+	}
+	isclosed := func(conn *redisgo.Conn, fc *FaultConn) {
+		t.Helper()
+		if conn.Err() == nil || !fc.Closed() {
+			t.Fatal("conn not closed on error")
+		}
+		if _, err := conn.Do("PING"); err != conn.Err() {
+			t.Fatal("expect", conn.Err(), "get", err)
+		}
+	}
+
+	// reset in the middle of a reply
+	fc := NewFaultConn(s.Pipe(), Faults{ResetAfterRead: 10})
+	conn := redisgo.NewConn(fc)
+	expect(t, do(t, conn, "SET", "k", "hello"), "OK") // 5 bytes
+	if _, err := conn.Do("GET", "k"); !isreset(err) {
+		t.Fatal(err)
+	}
+	isclosed(conn, fc)
+	if fc.BytesRead() != 10 {
+		t.Fatal(fc.BytesRead())
+	}
+
+	// reset while writing a command
+	fc = NewFaultConn(s.Pipe(), Faults{ResetAfterWrite: 10})
+	conn = redisgo.NewConn(fc)
+	if _, err := conn.Do("GET", "k"); !isreset(err) {
+		t.Fatal(err)
+	}
+	isclosed(conn, fc)
+
+	// stalled read triggers read timeout
+	fc = NewFaultConn(s.Pipe(), Faults{StallAfter: 2})
+	conn = redisgo.NewConn(fc, redisgo.WithReadTimeout(20*time.Millisecond))
+	t0 := time.Now()
+	_, err := conn.Do("PING")
+	if e, ok := err.(net.Error); !ok || !e.Timeout() {
+		t.Fatal(err)
+	}
+	if time.Since(t0) < 20*time.Millisecond {
+		t.Fatal("stall not work")
+	}
+	isclosed(conn, fc)
+
+	// stalled and resumed
+	fc = NewFaultConn(s.Pipe(), Faults{})
+	conn = redisgo.NewConn(fc)
+	fc.Stall()
+	time.AfterFunc(10*time.Millisecond, fc.Resume)
+	expect(t, do(t, conn, "PING"), "PONG")
+
+	// corrupted reply
+	fc = NewFaultConn(s.Pipe(), Faults{Corrupt: map[int64]byte{0: '!'}})
+	conn = redisgo.NewConn(fc)
+	var perr *redisgo.ProtocolError
+	if _, err := conn.Do("PING"); !errors.As(err, &perr) {
+		t.Fatal(err)
+	}
+	isclosed(conn, fc)
+}
+
+func TestDialWithFaults(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	pool := redisgo.NewPool(DialWithFaults(s.Dial, Faults{Latency: time.Millisecond, ReadSize: 3}))
+	c, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, ok := c.Conn.Conn().(*FaultConn); !ok {
+		t.Fatal("not FaultConn")
+	}
+	t0 := time.Now()
+	expect(t, do(t, c.Conn, "ECHO", "hello"), "hello")
+	if time.Since(t0) < 2*time.Millisecond {
+		t.Fatal("latency not work")
+	}
+}
@@ -0,0 +1,214 @@
+package redistest
+
+import (
+	"sort"
+	"strconv"
+)
+
+func init() {
+	register("HSET", -4, cmdHSet)
+	register("HMSET", -4, cmdHSet)
+	register("HSETNX", 4, cmdHSetNX)
+	register("HGET", 3, cmdHGet)
+	register("HMGET", -3, cmdHMGet)
+	register("HGETALL", 2, cmdHGetAll)
+	register("HDEL", -3, cmdHDel)
+	register("HEXISTS", 3, cmdHExists)
+	register("HLEN", 2, cmdHLen)
+	register("HKEYS", 2, cmdHKeys)
+	register("HVALS", 2, cmdHVals)
+	register("HINCRBY", 4, cmdHIncrBy)
+	register("HINCRBYFLOAT", 4, cmdHIncrByFloat)
+	register("HSCAN", -3, cmdHScan)
+}
+
+func (h hash) keys() []string {
+	ret := make([]string, 0, len(h))
+	for k := range h {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func cmdHSet(x *cmdctx) {
+	if len(x.args)%2 != 1 {
+		x.errargs()
+		return
+	}
+	h, ok := x.gethash(x.args[0], true)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	for i := 1; i < len(x.args); i += 2 {
+		if _, exists := h[x.args[i]]; !exists {
+			n++
+		}
+		h[x.args[i]] = x.args[i+1]
+	}
+	x.db.touch(x.args[0])
+	if x.name() == "HMSET" {
+		x.ok()
+		return
+	}
+	x.integer(n)
+}
+
+func cmdHSetNX(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], true)
+	if !ok {
+		return
+	}
+	if _, exists := h[x.args[1]]; exists {
+		x.integer(0)
+		return
+	}
+	h[x.args[1]] = x.args[2]
+	x.db.touch(x.args[0])
+	x.integer(1)
+}
+
+func cmdHGet(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if !ok {
+		return
+	}
+	if v, exists := h[x.args[1]]; exists {
+		x.bulk(v)
+		return
+	}
+	x.w.WriteNil()
+}
+
+func cmdHMGet(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if !ok {
+		return
+	}
+	x.w.WriteArrayHeader(len(x.args) - 1)
+	for _, f := range x.args[1:] {
+		if v, exists := h[f]; exists {
+			x.bulk(v)
+		} else {
+			x.w.WriteNil()
+		}
+	}
+}
+
+func cmdHGetAll(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if !ok {
+		return
+	}
+	ret := make([]string, 0, 2*len(h))
+	for _, k := range h.keys() {
+		ret = append(ret, k, h[k])
+	}
+	x.array(ret)
+}
+
+func cmdHDel(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	for _, f := range x.args[1:] {
+		if _, exists := h[f]; exists {
+			delete(h, f)
+			n++
+		}
+	}
+	if n > 0 {
+		x.cleanup(x.args[0])
+	}
+	x.integer(n)
+}
+
+func cmdHExists(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if ok {
+		_, exists := h[x.args[1]]
+		x.boolean(exists)
+	}
+}
+
+func cmdHLen(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if ok {
+		x.integer(int64(len(h)))
+	}
+}
+
+func cmdHKeys(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if ok {
+		x.array(h.keys())
+	}
+}
+
+func cmdHVals(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if !ok {
+		return
+	}
+	ret := make([]string, 0, len(h))
+	for _, k := range h.keys() {
+		ret = append(ret, h[k])
+	}
+	x.array(ret)
+}
+
+func cmdHIncrBy(x *cmdctx) {
+	delta, ok := x.parseint(2)
+	if !ok {
+		return
+	}
+	h, ok := x.gethash(x.args[0], true)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	if v, exists := h[x.args[1]]; exists {
+		var err error
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			x.err("ERR hash value is not an integer")
+			return
+		}
+	}
+	n += delta
+	h[x.args[1]] = strconv.FormatInt(n, 10)
+	x.db.touch(x.args[0])
+	x.integer(n)
+}
+
+func cmdHIncrByFloat(x *cmdctx) {
+	delta, ok := x.parsefloat(2)
+	if !ok {
+		return
+	}
+	h, ok := x.gethash(x.args[0], true)
+	if !ok {
+		return
+	}
+	f := 0.0
+	if v, exists := h[x.args[1]]; exists {
+		var err error
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			x.err("ERR hash value is not a float")
+			return
+		}
+	}
+	f += delta
+	h[x.args[1]] = formatfloat(f)
+	x.db.touch(x.args[0])
+	x.float(f)
+}
+
+func cmdHScan(x *cmdctx) {
+	h, ok := x.gethash(x.args[0], false)
+	if ok {
+		x.scan(h.keys(), x.args[1:], func(k string) string { return h[k] }, false)
+	}
+}
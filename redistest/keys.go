@@ -0,0 +1,246 @@
+package redistest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	register("PING", -1, cmdPing)
+	register("ECHO", 2, func(x *cmdctx) { x.bulk(x.args[0]) })
+	register("QUIT", 1, func(x *cmdctx) { x.ok(); x.c.conn.Close() })
+	register("SELECT", 2, cmdSelect)
+	register("DBSIZE", 1, cmdDBSize)
+	register("FLUSHDB", -1, func(x *cmdctx) { x.db.flush(); x.ok() })
+	register("FLUSHALL", -1, cmdFlushAll)
+
+	register("DEL", -2, cmdDel)
+	register("UNLINK", -2, cmdDel)
+	register("EXISTS", -2, cmdExists)
+	register("TYPE", 2, cmdType)
+	register("KEYS", 2, func(x *cmdctx) { x.array(x.db.keys(x.now, x.args[0])) })
+	register("RENAME", 3, cmdRename)
+	register("EXPIRE", -3, cmdExpire)
+	register("PEXPIRE", -3, cmdExpire)
+	register("EXPIREAT", -3, cmdExpire)
+	register("PEXPIREAT", -3, cmdExpire)
+	register("TTL", 2, cmdTTL)
+	register("PTTL", 2, cmdTTL)
+	register("PERSIST", 2, cmdPersist)
+	register("SCAN", -2, cmdScan)
+}
+
+func cmdPing(x *cmdctx) {
+	if x.c.subs != nil && x.c.subs.count() > 0 {
+		msg := ""
+		if len(x.args) > 0 {
+			msg = x.args[0]
+		}
+		x.array([]string{"pong", msg})
+		return
+	}
+	if len(x.args) > 0 {
+		x.bulk(x.args[0])
+		return
+	}
+	x.w.WriteSimpleString("PONG")
+}
+
+func cmdSelect(x *cmdctx) {
+	n, err := strconv.Atoi(x.args[0])
+	if err != nil || n < 0 || n >= numdbs {
+		x.err("ERR DB index is out of range")
+		return
+	}
+	x.c.db = n
+	x.ok()
+}
+
+func cmdDBSize(x *cmdctx) {
+	x.integer(int64(len(x.db.keys(x.now, "*"))))
+}
+
+func cmdFlushAll(x *cmdctx) {
+	for _, db := range x.s.dbs {
+		db.flush()
+	}
+	x.ok()
+}
+
+func cmdDel(x *cmdctx) {
+	n := int64(0)
+	for _, k := range x.args {
+		if x.db.get(x.now, k) != nil && x.db.del(k) {
+			n++
+		}
+	}
+	x.integer(n)
+}
+
+func cmdExists(x *cmdctx) {
+	n := int64(0)
+	for _, k := range x.args {
+		if x.db.get(x.now, k) != nil {
+			n++
+		}
+	}
+	x.integer(n)
+}
+
+func cmdType(x *cmdctx) {
+	e := x.db.get(x.now, x.args[0])
+	if e == nil {
+		x.w.WriteSimpleString("none")
+		return
+	}
+	x.w.WriteSimpleString(typename(e.v))
+}
+
+func cmdRename(x *cmdctx) {
+	e := x.db.get(x.now, x.args[0])
+	if e == nil {
+		x.err("ERR no such key")
+		return
+	}
+	x.db.del(x.args[0])
+	x.db.m[x.args[1]] = e
+	x.db.touch(x.args[1])
+	x.ok()
+}
+
+// cmdExpire handles EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT with NX, XX, GT, LT options
+func cmdExpire(x *cmdctx) {
+	n, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	var t time.Time
+	switch x.name() {
+	case "EXPIRE":
+		t = x.now.Add(time.Duration(n) * time.Second)
+	case "PEXPIRE":
+		t = x.now.Add(time.Duration(n) * time.Millisecond)
+	case "EXPIREAT":
+		t = time.Unix(n, 0)
+	case "PEXPIREAT":
+		t = time.Unix(0, n*int64(time.Millisecond))
+	}
+	e := x.db.get(x.now, x.args[0])
+	if e == nil {
+		x.integer(0)
+		return
+	}
+	for _, opt := range x.args[2:] {
+		switch strings.ToUpper(opt) {
+		case "NX":
+			ok = ok && e.expireAt.IsZero()
+		case "XX":
+			ok = ok && !e.expireAt.IsZero()
+		case "GT":
+			ok = ok && !e.expireAt.IsZero() && t.After(e.expireAt)
+		case "LT":
+			ok = ok && (e.expireAt.IsZero() || t.Before(e.expireAt))
+		default:
+			x.err("ERR Unsupported option " + opt)
+			return
+		}
+	}
+	if !ok {
+		x.integer(0)
+		return
+	}
+	if !t.After(x.now) {
+		x.db.del(x.args[0])
+	} else {
+		e.expireAt = t
+		x.db.touch(x.args[0])
+	}
+	x.integer(1)
+}
+
+func cmdTTL(x *cmdctx) {
+	e := x.db.get(x.now, x.args[0])
+	switch {
+	case e == nil:
+		x.integer(-2)
+	case e.expireAt.IsZero():
+		x.integer(-1)
+	case x.name() == "TTL":
+		x.integer(int64((e.expireAt.Sub(x.now) + time.Second/2) / time.Second))
+	default:
+		x.integer(int64(e.expireAt.Sub(x.now) / time.Millisecond))
+	}
+}
+
+func cmdPersist(x *cmdctx) {
+	e := x.db.get(x.now, x.args[0])
+	if e == nil || e.expireAt.IsZero() {
+		x.integer(0)
+		return
+	}
+	e.expireAt = time.Time{}
+	x.db.touch(x.args[0])
+	x.integer(1)
+}
+
+func cmdScan(x *cmdctx) {
+	x.scan(x.db.keys(x.now, "*"), x.args, nil, true)
+}
+
+// scan replies a page of elements and the next cursor.
+// args starts with the cursor, and values are replied after elements if not nil.
+func (x *cmdctx) scan(elements []string, args []string, values func(k string) string, typeopt bool) {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		x.err("ERR invalid cursor")
+		return
+	}
+	pattern, count, typ := "*", 10, ""
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			x.err(errSyntax)
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 1 {
+				x.err(errSyntax)
+				return
+			}
+		case "TYPE":
+			if !typeopt {
+				x.err(errSyntax)
+				return
+			}
+			typ = strings.ToLower(args[i+1])
+		default:
+			x.err(errSyntax)
+			return
+		}
+	}
+	var page []string
+	next := cursor
+	for ; next < len(elements) && next < cursor+count; next++ {
+		k := elements[next]
+		if !match(pattern, k) {
+			continue
+		}
+		if typ != "" && typename(x.db.m[k].v) != typ {
+			continue
+		}
+		page = append(page, k)
+		if values != nil {
+			page = append(page, values(k))
+		}
+	}
+	if next >= len(elements) {
+		next = 0
+	}
+	x.w.WriteArrayHeader(2)
+	x.bulk(strconv.Itoa(next))
+	x.array(page)
+}
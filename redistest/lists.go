@@ -0,0 +1,249 @@
+package redistest
+
+func init() {
+	register("LPUSH", -3, cmdPush)
+	register("RPUSH", -3, cmdPush)
+	register("LPUSHX", -3, cmdPush)
+	register("RPUSHX", -3, cmdPush)
+	register("LPOP", -2, cmdPop)
+	register("RPOP", -2, cmdPop)
+	register("LLEN", 2, cmdLLen)
+	register("LRANGE", 4, cmdLRange)
+	register("LINDEX", 3, cmdLIndex)
+	register("LSET", 4, cmdLSet)
+	register("LREM", 4, cmdLRem)
+	register("LTRIM", 4, cmdLTrim)
+}
+
+// normrange converts start and stop which may be negative to [start, stop) in [0, n]
+func normrange(start, stop int64, n int) (int, int) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= int64(n) {
+		stop = int64(n) - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop) + 1
+}
+
+// cmdPush handles LPUSH, RPUSH, LPUSHX and RPUSHX
+func cmdPush(x *cmdctx) {
+	name := x.name()
+	mustexist := name[len(name)-1] == 'X'
+	if mustexist && x.db.get(x.now, x.args[0]) == nil {
+		x.integer(0)
+		return
+	}
+	l, ok := x.getlist(x.args[0], true)
+	if !ok {
+		return
+	}
+	for _, v := range x.args[1:] {
+		if name[0] == 'L' {
+			l.a = append([]string{v}, l.a...)
+		} else {
+			l.a = append(l.a, v)
+		}
+	}
+	x.db.touch(x.args[0])
+	x.integer(int64(len(l.a)))
+}
+
+// cmdPop handles LPOP key [count] and RPOP key [count]
+func cmdPop(x *cmdctx) {
+	count := int64(-1)
+	if len(x.args) > 2 {
+		x.errargs()
+		return
+	}
+	if len(x.args) == 2 {
+		var ok bool
+		if count, ok = x.parseint(1); !ok {
+			return
+		}
+		if count < 0 {
+			x.err("ERR value is out of range, must be positive")
+			return
+		}
+	}
+	l, ok := x.getlist(x.args[0], false)
+	if !ok {
+		return
+	}
+	if l == nil {
+		if count < 0 {
+			x.w.WriteNil()
+		} else {
+			x.w.WriteNilArray()
+		}
+		return
+	}
+	n := int(count)
+	if count < 0 {
+		n = 1
+	}
+	if n > len(l.a) {
+		n = len(l.a)
+	}
+	var ret []string
+	if x.name() == "LPOP" {
+		ret = append(ret, l.a[:n]...)
+		l.a = l.a[n:]
+	} else {
+		for i := 0; i < n; i++ {
+			ret = append(ret, l.a[len(l.a)-1-i])
+		}
+		l.a = l.a[:len(l.a)-n]
+	}
+	x.cleanup(x.args[0])
+	if count < 0 {
+		x.bulk(ret[0])
+		return
+	}
+	x.array(ret)
+}
+
+func cmdLLen(x *cmdctx) {
+	l, ok := x.getlist(x.args[0], false)
+	if !ok {
+		return
+	}
+	if l == nil {
+		x.integer(0)
+		return
+	}
+	x.integer(int64(len(l.a)))
+}
+
+func cmdLRange(x *cmdctx) {
+	start, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	stop, ok := x.parseint(2)
+	if !ok {
+		return
+	}
+	l, ok := x.getlist(x.args[0], false)
+	if !ok {
+		return
+	}
+	if l == nil {
+		x.array(nil)
+		return
+	}
+	i, j := normrange(start, stop, len(l.a))
+	x.array(l.a[i:j])
+}
+
+func cmdLIndex(x *cmdctx) {
+	idx, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	l, ok := x.getlist(x.args[0], false)
+	if !ok {
+		return
+	}
+	if l != nil && idx < 0 {
+		idx += int64(len(l.a))
+	}
+	if l == nil || idx < 0 || idx >= int64(len(l.a)) {
+		x.w.WriteNil()
+		return
+	}
+	x.bulk(l.a[idx])
+}
+
+func cmdLSet(x *cmdctx) {
+	idx, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	l, ok := x.getlist(x.args[0], false)
+	if !ok {
+		return
+	}
+	if l == nil {
+		x.err("ERR no such key")
+		return
+	}
+	if idx < 0 {
+		idx += int64(len(l.a))
+	}
+	if idx < 0 || idx >= int64(len(l.a)) {
+		x.err("ERR index out of range")
+		return
+	}
+	l.a[idx] = x.args[2]
+	x.db.touch(x.args[0])
+	x.ok()
+}
+
+func cmdLRem(x *cmdctx) {
+	count, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	l, ok := x.getlist(x.args[0], false)
+	if !ok {
+		return
+	}
+	if l == nil {
+		x.integer(0)
+		return
+	}
+	v := x.args[2]
+	removed := int64(0)
+	keep := make([]string, 0, len(l.a))
+	if count >= 0 {
+		for _, e := range l.a {
+			if e == v && (count == 0 || removed < count) {
+				removed++
+				continue
+			}
+			keep = append(keep, e)
+		}
+	} else { // removes from tail
+		for i := len(l.a) - 1; i >= 0; i-- {
+			if l.a[i] == v && removed < -count {
+				removed++
+				continue
+			}
+			keep = append([]string{l.a[i]}, keep...)
+		}
+	}
+	l.a = keep
+	x.cleanup(x.args[0])
+	x.integer(removed)
+}
+
+func cmdLTrim(x *cmdctx) {
+	start, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	stop, ok := x.parseint(2)
+	if !ok {
+		return
+	}
+	l, ok := x.getlist(x.args[0], false)
+	if !ok {
+		return
+	}
+	if l != nil {
+		i, j := normrange(start, stop, len(l.a))
+		l.a = append([]string(nil), l.a[i:j]...)
+		x.cleanup(x.args[0])
+	}
+	x.ok()
+}
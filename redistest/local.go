@@ -0,0 +1,32 @@
+package redistest
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+)
+
+// LocalAddr is the address of the real redis used by LocalPool
+const LocalAddr = "127.0.0.1:6379"
+
+// LocalPool returns a pool of the real redis on LocalAddr for tests which depend on the behavior of redis
+// like Lua scripts, tb is skipped if it's not available.
+func LocalPool(tb testing.TB) *redisgo.Pool {
+	tb.Helper()
+	conn, err := net.DialTimeout("tcp", LocalAddr, time.Second)
+	if err != nil {
+		tb.Skip("redis is not available:", err)
+	}
+	conn.Close()
+	return redisgo.NewPool(func(ctx context.Context) (*redisgo.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", LocalAddr)
+		if err != nil {
+			return nil, err
+		}
+		return redisgo.NewConn(conn), nil
+	})
+}
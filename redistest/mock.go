@@ -0,0 +1,349 @@
+package redistest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/resp"
+	"github.com/xiaost/redisgo/server"
+)
+
+// Status is a simple string reply like "OK" or "PONG"
+type Status string
+
+// OK is the simple string reply "OK"
+const OK = Status("OK")
+
+// Mock is a scriptable redis connection which replies commands by expectations,
+// it checks the exact commands sent by the code under test.
+//
+//	m := redistest.NewMock()
+//	m.Expect("SET", "k", "v").Return(redistest.OK)
+//	conn := redisgo.NewConn(m.Conn())
+//	...
+//	if err := m.ExpectationsWereMet(); err != nil {
+//		t.Fatal(err)
+//	}
+type Mock struct {
+	srv *server.Server
+
+	mu         sync.Mutex
+	expects    []*Expectation
+	unexpected []string
+}
+
+// Expectation is an expected command of Mock and the way to reply it
+type Expectation struct {
+	cmd   string
+	args  [][]byte
+	match func(args [][]byte) bool
+
+	reply  interface{}
+	delay  time.Duration
+	neterr error
+	times  int // remaining times
+}
+
+// NewMock returns a new Mock without expectations
+func NewMock() *Mock {
+	m := &Mock{}
+	m.srv = &server.Server{Handler: server.HandlerFunc(m.serve)}
+	return m
+}
+
+// Expect adds an expectation of the command with exactly the args.
+// args are formatted the same way as redisgo.Conn.Do, it panics if any of them is invalid.
+// The expectation replies OK by default.
+func (m *Mock) Expect(cmd string, args ...interface{}) *Expectation {
+	var buf bytes.Buffer
+	enc := resp.NewEncoder(&buf)
+	if err := enc.WriteCommand(cmd, args...); err != nil {
+		panic("redistest: " + err.Error())
+	}
+	enc.Flush()
+	r := resp.NewReply()
+	defer r.Free()
+	if err := resp.NewDecoder(&buf).DecodeCommand(r); err != nil {
+		panic("redistest: " + err.Error())
+	}
+	aa, _ := r.Array()
+	e := &Expectation{cmd: cmd, reply: OK, times: 1}
+	for _, a := range aa[1:] {
+		b, _ := a.Bytes()
+		e.args = append(e.args, append([]byte{}, b...))
+	}
+	return m.add(e)
+}
+
+// ExpectFunc adds an expectation of the command with args matched by the predicate.
+// args passed to match do not include the command name.
+func (m *Mock) ExpectFunc(cmd string, match func(args [][]byte) bool) *Expectation {
+	return m.add(&Expectation{cmd: cmd, match: match, reply: OK, times: 1})
+}
+
+func (m *Mock) add(e *Expectation) *Expectation {
+	m.mu.Lock()
+	m.expects = append(m.expects, e)
+	m.mu.Unlock()
+	return e
+}
+
+// Return sets the reply of the expectation, v can be:
+//
+//	nil:                        nil bulk string
+//	Status:                     simple string
+//	error:                      error reply
+//	string, []byte:             bulk string
+//	int, int64, bool:           integer
+//	float64:                    bulk string formatted like redis
+//	[]string, [][]byte, []int64, []interface{}: array of the elements above
+//	*redisgo.Reply:             the reply as is
+//
+// It panics if v is of other types.
+func (e *Expectation) Return(v interface{}) *Expectation {
+	if err := writevalue(resp.NewEncoder(io.Discard), v); err != nil {
+		panic(err)
+	}
+	e.reply = v
+	return e
+}
+
+// ReturnError sets the reply of the expectation to an error reply like "ERR something"
+func (e *Expectation) ReturnError(msg string) *Expectation {
+	e.reply = resp.RedisErr(msg)
+	return e
+}
+
+// Delay delays the reply of the expectation by d
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.delay = d
+	return e
+}
+
+// NetError closes the connection instead of replying,
+// then reads and writes of the client side return err.
+// Replies of previous commands are written before closing.
+func (e *Expectation) NetError(err error) *Expectation {
+	e.neterr = err
+	return e
+}
+
+// Times sets the expectation to be matched n times, default: 1
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) matches(args [][]byte) bool {
+	if !strings.EqualFold(e.cmd, string(args[0])) {
+		return false
+	}
+	args = args[1:]
+	if e.match != nil {
+		return e.match(args)
+	}
+	if len(args) != len(e.args) {
+		return false
+	}
+	for i := range args {
+		if !bytes.Equal(args[i], e.args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Expectation) String() string {
+	if e.match != nil {
+		return e.cmd + " <func>"
+	}
+	return fmtcmd(append([][]byte{[]byte(e.cmd)}, e.args...))
+}
+
+func fmtcmd(args [][]byte) string {
+	ss := make([]string, len(args))
+	for i, a := range args {
+		ss[i] = strconv.Quote(string(a))
+	}
+	return strings.Join(ss, " ")
+}
+
+// Conn returns a new client side net.Conn of the Mock,
+// all conns of a Mock share the same expectations.
+func (m *Mock) Conn() net.Conn {
+	c0, c1 := net.Pipe()
+	c := &mockconn{Conn: c0}
+	go m.srv.ServeConn(&mockpeer{Conn: c1, c: c})
+	return c
+}
+
+// Dial returns a Conn over a new Conn of the Mock, it can be used as redisgo.DialFunc
+func (m *Mock) Dial(ctx context.Context) (*redisgo.Conn, error) {
+	return redisgo.NewConn(m.Conn()), nil
+}
+
+// Close closes all conns of the Mock
+func (m *Mock) Close() error {
+	return m.srv.Close()
+}
+
+// ExpectationsWereMet returns an error describing expectations not matched and unexpected commands,
+// or nil if all expectations were consumed.
+func (m *Mock) ExpectationsWereMet() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var msgs []string
+	for _, e := range m.expects {
+		if e.times > 0 {
+			msgs = append(msgs, fmt.Sprintf("expected command not called: %s (%d times left)", e, e.times))
+		}
+	}
+	for _, s := range m.unexpected {
+		msgs = append(msgs, "unexpected command: "+s)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New("redistest: " + strings.Join(msgs, "; "))
+}
+
+func (m *Mock) find(args [][]byte) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expects {
+		if e.times > 0 && e.matches(args) {
+			e.times--
+			return e
+		}
+	}
+	m.unexpected = append(m.unexpected, fmtcmd(args))
+	return nil
+}
+
+func (m *Mock) serve(w server.ResponseWriter, r *server.Request) {
+	e := m.find(r.Args)
+	if e == nil {
+		w.WriteError("ERR redistest: unexpected command " + fmtcmd(r.Args))
+		return
+	}
+	if e.delay > 0 {
+		time.Sleep(e.delay)
+	}
+	if e.neterr != nil {
+		if f, ok := w.(interface{ Flush() error }); ok {
+			f.Flush() // replies of previous commands
+		}
+		p := r.Conn.NetConn().(*mockpeer)
+		p.c.fail(e.neterr)
+		p.Close()
+		return
+	}
+	writevalue(w, e.reply)
+}
+
+func writevalue(w server.ResponseWriter, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		return w.WriteNil()
+	case Status:
+		return w.WriteSimpleString(string(v))
+	case error:
+		return w.WriteError(v.Error())
+	case string:
+		return w.WriteBulkString(v)
+	case []byte:
+		return w.WriteBulk(v)
+	case int:
+		return w.WriteInteger(int64(v))
+	case int64:
+		return w.WriteInteger(v)
+	case bool:
+		if v {
+			return w.WriteInteger(1)
+		}
+		return w.WriteInteger(0)
+	case float64:
+		return w.WriteBulkString(formatfloat(v))
+	case *resp.Reply:
+		return w.WriteReply(v)
+	case []string:
+		w.WriteArrayHeader(len(v))
+		for _, s := range v {
+			w.WriteBulkString(s)
+		}
+	case [][]byte:
+		w.WriteArrayHeader(len(v))
+		for _, b := range v {
+			w.WriteBulk(b)
+		}
+	case []int64:
+		w.WriteArrayHeader(len(v))
+		for _, i := range v {
+			w.WriteInteger(i)
+		}
+	case []interface{}:
+		w.WriteArrayHeader(len(v))
+		for _, e := range v {
+			if err := writevalue(w, e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("redistest: unsupported reply type %T", v)
+	}
+	return nil
+}
+
+// mockconn is the client side of a Mock conn
+type mockconn struct {
+	net.Conn
+
+	mu  sync.Mutex
+	err error
+}
+
+func (c *mockconn) fail(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+}
+
+func (c *mockconn) failure(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return err
+}
+
+func (c *mockconn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		err = c.failure(err)
+	}
+	return n, err
+}
+
+func (c *mockconn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if err != nil {
+		err = c.failure(err)
+	}
+	return n, err
+}
+
+// mockpeer is the server side of a Mock conn
+type mockpeer struct {
+	net.Conn
+	c *mockconn
+}
@@ -0,0 +1,80 @@
+package redistest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+)
+
+func TestMock(t *testing.T) {
+	m := NewMock()
+	defer m.Close()
+	m.Expect("SET", "k", 1).Return(OK)
+	m.Expect("GET", "k").Return("1").Times(2)
+	m.ExpectFunc("HSET", func(args [][]byte) bool {
+		return len(args) == 3 && bytes.HasPrefix(args[0], []byte("user:"))
+	}).Return(1)
+	m.Expect("LRANGE", "l", 0, -1).Return([]interface{}{"a", nil, int64(2)})
+	m.Expect("INCR", "s").ReturnError("ERR value is not an integer or out of range")
+
+	pool := redisgo.NewPool(m.Dial)
+	c, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn := c.Conn
+
+	expect(t, do(t, conn, "SET", "k", "1"), "OK")
+	expect(t, do(t, conn, "GET", "k"), "1")
+	expect(t, do(t, conn, "get", "k"), "1")
+	expect(t, do(t, conn, "HSET", "user:1", "name", "x"), int64(1))
+	expect(t, do(t, conn, "LRANGE", "l", 0, -1), a("a", nil, int64(2)))
+	if _, err := conn.DoInteger("INCR", "s"); err == nil {
+		t.Fatal("expect error reply")
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	// unexpected and unconsumed
+	m.Expect("DEL", "k")
+	if _, ok := do(t, conn, "GET", "k").(error); !ok {
+		t.Fatal("expect error reply")
+	}
+	err = m.ExpectationsWereMet()
+	if err == nil || !bytes.Contains([]byte(err.Error()), []byte(`"GET" "k"`)) ||
+		!bytes.Contains([]byte(err.Error()), []byte(`"DEL" "k"`)) {
+		t.Fatal(err)
+	}
+}
+
+func TestMockFaults(t *testing.T) {
+	m := NewMock()
+	defer m.Close()
+	m.Expect("PING").Return(Status("PONG")).Delay(50 * time.Millisecond)
+	m.Expect("PING").Return(Status("PONG"))
+	neterr := errors.New("connection reset by peer")
+	m.Expect("GET", "k").NetError(neterr)
+
+	conn := redisgo.NewConn(m.Conn(), redisgo.WithReadTimeout(10*time.Millisecond))
+	if _, err := conn.Do("PING"); err == nil {
+		t.Fatal("expect timeout")
+	}
+	if conn.Err() == nil {
+		t.Fatal("expect conn closed")
+	}
+
+	conn = redisgo.NewConn(m.Conn())
+	expect(t, do(t, conn, "PING"), "PONG")
+	if _, err := conn.Do("GET", "k"); err != neterr {
+		t.Fatal(err)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
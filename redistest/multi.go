@@ -0,0 +1,87 @@
+package redistest
+
+// txcommands are executed immediately in MULTI
+var txcommands = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true, "UNWATCH": true,
+}
+
+func init() {
+	register("MULTI", 1, cmdMulti)
+	register("EXEC", 1, cmdExec)
+	register("DISCARD", 1, cmdDiscard)
+	register("WATCH", -2, cmdWatch)
+	register("UNWATCH", 1, cmdUnwatch)
+}
+
+func (c *client) resettx() {
+	c.multi = false
+	c.dirty = false
+	c.queued = nil
+	c.watches = nil
+}
+
+func cmdMulti(x *cmdctx) {
+	if x.c.multi {
+		x.err("ERR MULTI calls can not be nested")
+		return
+	}
+	x.c.multi = true
+	x.ok()
+}
+
+func cmdExec(x *cmdctx) {
+	c := x.c
+	if !c.multi {
+		x.err("ERR EXEC without MULTI")
+		return
+	}
+	defer c.resettx()
+	if c.dirty {
+		x.err("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+	for k, v := range c.watches {
+		db := x.s.dbs[k.db]
+		db.get(x.now, k.key) // expired keys are modified
+		if db.versions[k.key] != v {
+			x.w.WriteNilArray()
+			return
+		}
+	}
+	x.w.WriteArrayHeader(len(c.queued))
+	for _, cmd := range c.queued {
+		x.s.exec(c, x.w, cmd[0], cmd[1:])
+	}
+}
+
+func cmdDiscard(x *cmdctx) {
+	if !x.c.multi {
+		x.err("ERR DISCARD without MULTI")
+		return
+	}
+	x.c.resettx()
+	x.ok()
+}
+
+func cmdWatch(x *cmdctx) {
+	if x.c.multi {
+		x.err("ERR WATCH inside MULTI is not allowed")
+		return
+	}
+	if x.c.watches == nil {
+		x.c.watches = make(map[watchkey]uint64)
+	}
+	for _, k := range x.args {
+		x.db.get(x.now, k)
+		wk := watchkey{db: x.c.db, key: k}
+		if _, ok := x.c.watches[wk]; !ok {
+			x.c.watches[wk] = x.db.versions[k]
+		}
+	}
+	x.ok()
+}
+
+func cmdUnwatch(x *cmdctx) {
+	x.c.watches = nil
+	x.ok()
+}
@@ -0,0 +1,195 @@
+package redistest
+
+import (
+	"sync"
+
+	"github.com/xiaost/redisgo/server"
+)
+
+// subcommands are allowed in the subscribed state
+var subcommands = map[string]bool{
+	"SUBSCRIBE": true, "UNSUBSCRIBE": true, "PSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+	"PING": true, "QUIT": true,
+}
+
+func init() {
+	register("SUBSCRIBE", -2, cmdSubscribe)
+	register("PSUBSCRIBE", -2, cmdSubscribe)
+	register("UNSUBSCRIBE", -1, cmdUnsubscribe)
+	register("PUNSUBSCRIBE", -1, cmdUnsubscribe)
+	register("PUBLISH", 3, cmdPublish)
+}
+
+// subscriber delivers messages to a connection in order
+type subscriber struct {
+	conn *server.Conn
+
+	// accessed by the goroutine of conn only
+	channels map[string]struct{}
+	patterns map[string]struct{}
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  [][]string
+	closed bool
+}
+
+func newsubscriber(conn *server.Conn) *subscriber {
+	sub := &subscriber{
+		conn:     conn,
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+	go sub.loop()
+	return sub
+}
+
+func (sub *subscriber) count() int {
+	return len(sub.channels) + len(sub.patterns)
+}
+
+func (sub *subscriber) push(msg []string) {
+	sub.mu.Lock()
+	sub.queue = append(sub.queue, msg)
+	sub.mu.Unlock()
+	sub.cond.Signal()
+}
+
+func (sub *subscriber) close() {
+	sub.mu.Lock()
+	sub.closed = true
+	sub.mu.Unlock()
+	sub.cond.Signal()
+}
+
+func (sub *subscriber) loop() {
+	for {
+		sub.mu.Lock()
+		for len(sub.queue) == 0 && !sub.closed {
+			sub.cond.Wait()
+		}
+		if sub.closed {
+			sub.mu.Unlock()
+			return
+		}
+		msg := sub.queue[0]
+		sub.queue = sub.queue[1:]
+		sub.mu.Unlock()
+		err := sub.conn.Write(func(w server.ResponseWriter) error {
+			w.WriteArrayHeader(len(msg))
+			for _, s := range msg {
+				w.WriteBulkString(s)
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// unsubscribeall removes c from m which is s.channels or s.patterns
+func (s *Server) unsubscribeall(c *client, m map[string]map[*client]struct{}) {
+	for name, cc := range m {
+		delete(cc, c)
+		if len(cc) == 0 {
+			delete(m, name)
+		}
+	}
+}
+
+// pubsubreply replies like ["subscribe", channel, count]
+func (x *cmdctx) pubsubreply(kind, name string, nilname bool) {
+	x.w.WriteArrayHeader(3)
+	x.bulk(kind)
+	if nilname {
+		x.w.WriteNil()
+	} else {
+		x.bulk(name)
+	}
+	x.integer(int64(x.c.subs.count()))
+}
+
+// cmdSubscribe handles SUBSCRIBE and PSUBSCRIBE
+func cmdSubscribe(x *cmdctx) {
+	if x.c.multi {
+		x.err("ERR " + x.name() + " isn't allowed in MULTI")
+		return
+	}
+	if x.c.subs == nil {
+		x.c.subs = newsubscriber(x.c.conn)
+	}
+	mine, all, kind := x.c.subs.channels, x.s.channels, "subscribe"
+	if x.name() == "PSUBSCRIBE" {
+		mine, all, kind = x.c.subs.patterns, x.s.patterns, "psubscribe"
+	}
+	for _, name := range x.args {
+		mine[name] = struct{}{}
+		if all[name] == nil {
+			all[name] = make(map[*client]struct{})
+		}
+		all[name][x.c] = struct{}{}
+		x.pubsubreply(kind, name, false)
+	}
+}
+
+// cmdUnsubscribe handles UNSUBSCRIBE and PUNSUBSCRIBE
+func cmdUnsubscribe(x *cmdctx) {
+	if x.c.subs == nil {
+		x.c.subs = newsubscriber(x.c.conn)
+	}
+	mine, all, kind := x.c.subs.channels, x.s.channels, "unsubscribe"
+	if x.name() == "PUNSUBSCRIBE" {
+		mine, all, kind = x.c.subs.patterns, x.s.patterns, "punsubscribe"
+	}
+	names := x.args
+	if len(names) == 0 {
+		names = sorted(mine)
+		if len(names) == 0 {
+			x.pubsubreply(kind, "", true)
+			return
+		}
+	}
+	for _, name := range names {
+		delete(mine, name)
+		if cc := all[name]; cc != nil {
+			delete(cc, x.c)
+			if len(cc) == 0 {
+				delete(all, name)
+			}
+		}
+		x.pubsubreply(kind, name, false)
+	}
+}
+
+func cmdPublish(x *cmdctx) {
+	ch, msg := x.args[0], x.args[1]
+	n := int64(0)
+	for c := range x.s.channels[ch] {
+		c.subs.push([]string{"message", ch, msg})
+		n++
+	}
+	for pattern, cc := range x.s.patterns {
+		if !match(pattern, ch) {
+			continue
+		}
+		for c := range cc {
+			c.subs.push([]string{"pmessage", pattern, ch, msg})
+			n++
+		}
+	}
+	x.integer(n)
+}
+
+// PubSubChannels returns the number of subscribers of channels,
+// it's for waiting subscribers ready in tests
+func (s *Server) PubSubChannels() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make(map[string]int, len(s.channels))
+	for ch, cc := range s.channels {
+		ret[ch] = len(cc)
+	}
+	return ret
+}
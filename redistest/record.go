@@ -0,0 +1,324 @@
+package redistest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/resp"
+	"github.com/xiaost/redisgo/server"
+)
+
+// RecordConn is a net.Conn which records both directions of the wrapped conn.
+//
+// Each chunk of data is recorded as a header line followed by the data and a LF:
+//
+//	<direction> <unix nano> <length>\n<data>\n
+//
+// direction is '>' for data written by the client, and '<' for data read by the client.
+type RecordConn struct {
+	net.Conn
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewRecordConn returns a RecordConn which wraps conn and records the session to w
+func NewRecordConn(conn net.Conn, w io.Writer) *RecordConn {
+	return &RecordConn{Conn: conn, w: w}
+}
+
+// Err returns the first error of writing the record
+func (c *RecordConn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *RecordConn) record(dir byte, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	buf := make([]byte, 0, len(b)+32)
+	buf = append(buf, dir, ' ')
+	buf = strconv.AppendInt(buf, time.Now().UnixNano(), 10)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(len(b)), 10)
+	buf = append(buf, '\n')
+	buf = append(buf, b...)
+	buf = append(buf, '\n')
+	_, c.err = c.w.Write(buf)
+}
+
+// Read reads data from the wrapped conn and records it
+func (c *RecordConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.record('<', b[:n])
+	return n, err
+}
+
+// Write writes data to the wrapped conn and records it
+func (c *RecordConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.record('>', b[:n])
+	return n, err
+}
+
+// Exchange is a command and its reply of a recorded session
+type Exchange struct {
+	Args  [][]byte
+	Reply *redisgo.Reply // nil if the reply was not recorded
+
+	// Sent is the time the command was completely written,
+	// Received is the time the reply was completely read.
+	Sent     time.Time
+	Received time.Time
+}
+
+// Session is a recorded session which is decoded into commands and replies.
+// Replies are matched with commands in order, so sessions with pub/sub messages are not supported.
+type Session struct {
+	Exchanges []Exchange
+}
+
+// stream is the data of one direction with the time of each chunk
+type stream struct {
+	buf    bytes.Buffer
+	ends   []int64 // end offsets of chunks
+	times  []time.Time
+	nread  int64
+	reader io.Reader
+}
+
+func (s *stream) add(t time.Time, b []byte) {
+	s.buf.Write(b)
+	s.ends = append(s.ends, int64(s.buf.Len()))
+	s.times = append(s.times, t)
+}
+
+// timeof returns the time of the chunk which contains the byte at off-1
+func (s *stream) timeof(off int64) time.Time {
+	i := sort.Search(len(s.ends), func(i int) bool { return s.ends[i] >= off })
+	if i == len(s.ends) {
+		i--
+	}
+	return s.times[i]
+}
+
+func (s *stream) Read(b []byte) (int, error) {
+	n, err := s.reader.Read(b)
+	s.nread += int64(n)
+	return n, err
+}
+
+// LoadSession decodes a session recorded by RecordConn,
+// a command or reply truncated at the end of the session is ignored.
+func LoadSession(r io.Reader) (*Session, error) {
+	var in, out stream // in: read by the client, out: written by the client
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redistest: read record: %w", err)
+		}
+		var dir byte
+		var ts, n int64
+		if _, err := fmt.Sscanf(line, "%c %d %d\n", &dir, &ts, &n); err != nil || (dir != '<' && dir != '>') || n < 0 {
+			return nil, fmt.Errorf("redistest: malformed record header %q", line)
+		}
+		b := make([]byte, n+1)
+		if _, err := io.ReadFull(br, b); err != nil || b[n] != '\n' {
+			return nil, fmt.Errorf("redistest: malformed record data after %q", line)
+		}
+		if dir == '>' {
+			out.add(time.Unix(0, ts), b[:n])
+		} else {
+			in.add(time.Unix(0, ts), b[:n])
+		}
+	}
+
+	s := &Session{}
+	out.reader = bytes.NewReader(out.buf.Bytes())
+	dec := resp.NewDecoder(&out)
+	for {
+		r := resp.NewReply()
+		err := dec.DecodeCommand(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redistest: decode command %d: %w", len(s.Exchanges), err)
+		}
+		aa, _ := r.Array()
+		e := Exchange{Args: make([][]byte, len(aa))}
+		for i := range aa {
+			e.Args[i], _ = aa[i].Bytes()
+		}
+		e.Sent = out.timeof(out.nread - int64(dec.Buffered()))
+		s.Exchanges = append(s.Exchanges, e)
+	}
+
+	in.reader = bytes.NewReader(in.buf.Bytes())
+	dec = resp.NewDecoder(&in)
+	for i := 0; ; i++ {
+		r := resp.NewReply()
+		err := dec.Decode(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redistest: decode reply %d: %w", i, err)
+		}
+		if i >= len(s.Exchanges) {
+			return nil, fmt.Errorf("redistest: reply %d without command", i)
+		}
+		s.Exchanges[i].Reply = r
+		s.Exchanges[i].Received = in.timeof(in.nread - int64(dec.Buffered()))
+	}
+	return s, nil
+}
+
+// Diff is a mismatch between a replayed command and the recorded one
+type Diff struct {
+	Index    int
+	Expected [][]byte // nil if more commands than recorded
+	Got      [][]byte // nil if the recorded command is not replayed
+}
+
+func (d Diff) String() string {
+	fmtargs := func(args [][]byte) string {
+		if args == nil {
+			return "<none>"
+		}
+		return fmtcmd(args)
+	}
+	return fmt.Sprintf("command %d: expected %s, got %s", d.Index, fmtargs(d.Expected), fmtargs(d.Got))
+}
+
+// ReplayServer serves recorded replies back for the same command sequence.
+// Commands of all conns are matched against the session in order,
+// the i-th command replayed is compared with the i-th command recorded.
+type ReplayServer struct {
+	srv *server.Server
+	s   *Session
+
+	// Realtime delays each reply by the recorded latency between the command and the reply
+	Realtime bool
+
+	mu    sync.Mutex
+	next  int
+	diffs []Diff
+}
+
+// NewReplayServer returns a new ReplayServer of the session
+func NewReplayServer(s *Session) *ReplayServer {
+	rs := &ReplayServer{s: s}
+	rs.srv = &server.Server{Handler: server.HandlerFunc(rs.serve)}
+	return rs
+}
+
+// Conn returns a new client side net.Conn of the ReplayServer
+func (rs *ReplayServer) Conn() net.Conn {
+	c0, c1 := net.Pipe()
+	go rs.srv.ServeConn(c1)
+	return c0
+}
+
+// Dial returns a Conn over a new Conn of the ReplayServer, it can be used as redisgo.DialFunc
+func (rs *ReplayServer) Dial(ctx context.Context) (*redisgo.Conn, error) {
+	return redisgo.NewConn(rs.Conn()), nil
+}
+
+// Close closes all conns of the ReplayServer
+func (rs *ReplayServer) Close() error {
+	return rs.srv.Close()
+}
+
+// Diffs returns mismatches of commands replayed so far
+func (rs *ReplayServer) Diffs() []Diff {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]Diff(nil), rs.diffs...)
+}
+
+// Err returns an error describing all mismatches including recorded commands not replayed,
+// or nil if the session is replayed exactly.
+func (rs *ReplayServer) Err() error {
+	diffs := rs.Diffs()
+	rs.mu.Lock()
+	for i := rs.next; i < len(rs.s.Exchanges); i++ {
+		diffs = append(diffs, Diff{Index: i, Expected: rs.s.Exchanges[i].Args})
+	}
+	rs.mu.Unlock()
+	if len(diffs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(diffs))
+	for i, d := range diffs {
+		msgs[i] = d.String()
+	}
+	return errors.New("redistest: replay mismatch: " + strings.Join(msgs, "; "))
+}
+
+func equalargs(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rs *ReplayServer) serve(w server.ResponseWriter, r *server.Request) {
+	args := make([][]byte, len(r.Args))
+	for i, a := range r.Args {
+		args[i] = append([]byte{}, a...)
+	}
+	rs.mu.Lock()
+	i := rs.next
+	if i >= len(rs.s.Exchanges) {
+		rs.diffs = append(rs.diffs, Diff{Index: i, Got: args})
+		rs.mu.Unlock()
+		w.WriteError("ERR redistest: unexpected command " + fmtcmd(args))
+		return
+	}
+	e := &rs.s.Exchanges[i]
+	rs.next++
+	if !equalargs(e.Args, args) {
+		rs.diffs = append(rs.diffs, Diff{Index: i, Expected: e.Args, Got: args})
+		rs.mu.Unlock()
+		w.WriteError("ERR redistest: command " + strconv.Itoa(i) + " mismatch, expected " + fmtcmd(e.Args))
+		return
+	}
+	rs.mu.Unlock()
+	if e.Reply == nil {
+		r.Conn.Close() // the session ended before the reply
+		return
+	}
+	if d := e.Received.Sub(e.Sent); rs.Realtime && d > 0 {
+		time.Sleep(d)
+	}
+	w.WriteReply(e.Reply)
+}
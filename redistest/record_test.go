@@ -0,0 +1,88 @@
+package redistest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+)
+
+func TestRecordReplay(t *testing.T) {
+	s := NewUnstartedServer()
+	defer s.Close()
+
+	var buf bytes.Buffer
+	rc := NewRecordConn(NewFaultConn(s.Pipe(), Faults{ReadSize: 3}), &buf)
+	conn := redisgo.NewConn(rc)
+	session := func(conn *redisgo.Conn, n int) {
+		expect(t, do(t, conn, "SET", "k", n), "OK")
+		expect(t, do(t, conn, "INCR", "k"), int64(n+1))
+		expect(t, do(t, conn, "RPUSH", "l", "a\r\nb", ""), int64(2))
+		expect(t, do(t, conn, "LRANGE", "l", 0, -1), a("a\r\nb", ""))
+		expect(t, do(t, conn, "GET", "none"), nil)
+		expect(t, do(t, conn, "HGET", "l", "f").(error).Error(), errWrongType)
+	}
+	session(conn, 1)
+	conn.Close()
+	if err := rc.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	ss, err := LoadSession(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss.Exchanges) != 6 {
+		t.Fatal(len(ss.Exchanges))
+	}
+	for _, e := range ss.Exchanges {
+		if e.Reply == nil || e.Sent.IsZero() || e.Received.Before(e.Sent) {
+			t.Fatalf("%+v", e)
+		}
+	}
+	expect(t, string(ss.Exchanges[3].Args[0]), "LRANGE")
+
+	// replay the same session
+	rs := NewReplayServer(ss)
+	defer rs.Close()
+	rs.Realtime = true
+	conn = redisgo.NewConn(rs.Conn())
+	session(conn, 1)
+	if err := rs.Err(); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// replay with a different command
+	rs = NewReplayServer(ss)
+	defer rs.Close()
+	conn = redisgo.NewConn(rs.Conn(), redisgo.WithReadTimeout(time.Second))
+	expect(t, do(t, conn, "SET", "k", 1), "OK")
+	if _, ok := do(t, conn, "INCR", "k2").(error); !ok {
+		t.Fatal("expect error reply")
+	}
+	diffs := rs.Diffs()
+	if len(diffs) != 1 || diffs[0].Index != 1 || string(diffs[0].Got[1]) != "k2" {
+		t.Fatal(diffs)
+	}
+	err = rs.Err()
+	if err == nil || !strings.Contains(err.Error(), `command 5: expected "HGET" "l" "f", got <none>`) {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSessionMalformed(t *testing.T) {
+	for _, s := range []string{
+		"x 1 2\nab\n",
+		"> 1 3\nab\n",
+		"> 1 2\nabc",
+		"> 1 4\n*x\r\n\n",
+		"< 1 5\n+OK\r\n\n",
+	} {
+		if _, err := LoadSession(strings.NewReader(s)); err == nil {
+			t.Fatal("expect err", s)
+		}
+	}
+}
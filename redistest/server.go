@@ -0,0 +1,342 @@
+// Package redistest provides utilities for testing code which uses redisgo.
+//
+// Server is an in-memory fake redis server, it supports commands of
+// strings, keys with TTL, hashes, lists, sets, sorted sets, MULTI/EXEC, pub/sub and SELECT,
+// so that Pool and Conn can be tested end to end without a real redis server.
+//
+//	s := redistest.NewServer()
+//	defer s.Close()
+//	pool := redisgo.NewPool(s.Dial)
+package redistest
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/server"
+)
+
+const numdbs = 16
+
+// Server is an in-memory fake redis server
+type Server struct {
+	srv *server.Server
+	l   net.Listener
+
+	mu       sync.Mutex
+	dbs      [numdbs]*db
+	frozen   bool
+	now      time.Time     // if frozen
+	offset   time.Duration // if not frozen
+	channels map[string]map[*client]struct{}
+	patterns map[string]map[*client]struct{}
+}
+
+// NewServer starts and returns a new Server listening on a loopback address.
+// It panics if failed to listen. The caller should call Close when finished.
+func NewServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("redistest: failed to listen: " + err.Error())
+	}
+	s := NewUnstartedServer()
+	s.l = l
+	go s.srv.Serve(l)
+	return s
+}
+
+// NewUnstartedServer returns a new Server without a listener,
+// connections can be created with Pipe or DialPipe.
+func NewUnstartedServer() *Server {
+	s := &Server{
+		channels: make(map[string]map[*client]struct{}),
+		patterns: make(map[string]map[*client]struct{}),
+	}
+	for i := range s.dbs {
+		s.dbs[i] = newdb()
+	}
+	s.srv = &server.Server{
+		Handler:      server.HandlerFunc(s.serve),
+		OnConnect:    s.onconnect,
+		OnDisconnect: s.ondisconnect,
+	}
+	return s
+}
+
+// Addr returns the listening address of the server, like "127.0.0.1:6379"
+func (s *Server) Addr() string {
+	if s.l == nil {
+		return ""
+	}
+	return s.l.Addr().String()
+}
+
+// Close closes the server and all connections
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// Dial connects to the server, it can be used as redisgo.DialFunc
+func (s *Server) Dial(ctx context.Context) (*redisgo.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr())
+	if err != nil {
+		return nil, err
+	}
+	return redisgo.NewConn(conn), nil
+}
+
+// Pipe returns the client side of a net.Pipe served by the server
+func (s *Server) Pipe() net.Conn {
+	c0, c1 := net.Pipe()
+	go s.srv.ServeConn(c1)
+	return c0
+}
+
+// DialPipe returns a Conn over Pipe, it can be used as redisgo.DialFunc
+func (s *Server) DialPipe(ctx context.Context) (*redisgo.Conn, error) {
+	return redisgo.NewConn(s.Pipe()), nil
+}
+
+// Now returns the current time of the server clock
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nowlocked()
+}
+
+func (s *Server) nowlocked() time.Time {
+	if s.frozen {
+		return s.now
+	}
+	return time.Now().Add(s.offset)
+}
+
+// SetTime freezes the server clock at t, keys expire by the clock
+func (s *Server) SetTime(t time.Time) {
+	s.mu.Lock()
+	s.frozen = true
+	s.now = t
+	s.mu.Unlock()
+}
+
+// FastForward moves the server clock forward by d
+func (s *Server) FastForward(d time.Duration) {
+	s.mu.Lock()
+	if s.frozen {
+		s.now = s.now.Add(d)
+	} else {
+		s.offset += d
+	}
+	s.mu.Unlock()
+}
+
+// FlushAll removes all keys of all databases
+func (s *Server) FlushAll() {
+	s.mu.Lock()
+	for _, db := range s.dbs {
+		db.flush()
+	}
+	s.mu.Unlock()
+}
+
+// Keys returns all keys not expired of database 0 in order
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dbs[0].keys(s.nowlocked(), "*")
+}
+
+// client represents the state of a connection
+type client struct {
+	conn *server.Conn
+	db   int
+
+	multi   bool
+	dirty   bool // error while queueing commands, EXEC is aborted
+	queued  [][]string
+	watches map[watchkey]uint64
+
+	subs *subscriber
+}
+
+type watchkey struct {
+	db  int
+	key string
+}
+
+func (s *Server) onconnect(conn *server.Conn) {
+	conn.SetValue(&client{conn: conn})
+}
+
+func (s *Server) ondisconnect(conn *server.Conn) {
+	c := conn.Value().(*client)
+	s.mu.Lock()
+	s.unsubscribeall(c, s.channels)
+	s.unsubscribeall(c, s.patterns)
+	s.mu.Unlock()
+	if c.subs != nil {
+		c.subs.close()
+	}
+}
+
+// cmdctx is the context of executing a command
+type cmdctx struct {
+	s    *Server
+	c    *client
+	w    server.ResponseWriter
+	db   *db
+	now  time.Time
+	cmd  string   // upper case command name
+	args []string // without command name
+}
+
+func (x *cmdctx) name() string {
+	return x.cmd
+}
+
+type cmdspec struct {
+	fn    func(x *cmdctx)
+	arity int // number of args including name if > 0, or at least -arity args if < 0
+}
+
+func (spec *cmdspec) checkarity(n int) bool {
+	if spec.arity > 0 {
+		return n == spec.arity
+	}
+	return n >= -spec.arity
+}
+
+var commands map[string]*cmdspec
+
+func register(name string, arity int, fn func(x *cmdctx)) {
+	if commands == nil {
+		commands = make(map[string]*cmdspec)
+	}
+	commands[name] = &cmdspec{fn: fn, arity: arity}
+}
+
+func (s *Server) serve(w server.ResponseWriter, r *server.Request) {
+	c := r.Conn.Value().(*client)
+	name := strings.ToUpper(r.Name())
+	args := make([]string, len(r.Args)-1)
+	for i, b := range r.Args[1:] {
+		args[i] = string(b)
+	}
+	spec := commands[name]
+	if spec == nil {
+		c.dirty = c.multi
+		w.WriteError("ERR unknown command '" + r.Name() + "'")
+		return
+	}
+	if !spec.checkarity(len(r.Args)) {
+		c.dirty = c.multi
+		w.WriteError("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		return
+	}
+	if c.subs != nil && c.subs.count() > 0 && !subcommands[name] {
+		w.WriteError("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+		return
+	}
+	if c.multi && !txcommands[name] {
+		c.queued = append(c.queued, append([]string{name}, args...))
+		w.WriteSimpleString("QUEUED")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exec(c, w, name, args)
+}
+
+// exec executes a command with s.mu held
+func (s *Server) exec(c *client, w server.ResponseWriter, name string, args []string) {
+	x := &cmdctx{s: s, c: c, w: w, db: s.dbs[c.db], now: s.nowlocked(), cmd: name, args: args}
+	commands[name].fn(x)
+}
+
+// reply helpers
+
+const (
+	errWrongType = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	errNotInt    = "ERR value is not an integer or out of range"
+	errNotFloat  = "ERR value is not a valid float"
+	errSyntax    = "ERR syntax error"
+)
+
+func (x *cmdctx) errargs() {
+	x.err("ERR wrong number of arguments for '" + strings.ToLower(x.cmd) + "' command")
+}
+
+func (x *cmdctx) ok() {
+	x.w.WriteSimpleString("OK")
+}
+
+func (x *cmdctx) err(s string) {
+	x.w.WriteError(s)
+}
+
+func (x *cmdctx) integer(i int64) {
+	x.w.WriteInteger(i)
+}
+
+func (x *cmdctx) boolean(b bool) {
+	if b {
+		x.w.WriteInteger(1)
+	} else {
+		x.w.WriteInteger(0)
+	}
+}
+
+func (x *cmdctx) bulk(s string) {
+	x.w.WriteBulkString(s)
+}
+
+func (x *cmdctx) float(f float64) {
+	x.w.WriteBulkString(formatfloat(f))
+}
+
+func (x *cmdctx) array(ss []string) {
+	x.w.WriteArrayHeader(len(ss))
+	for _, s := range ss {
+		x.w.WriteBulkString(s)
+	}
+}
+
+func formatfloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// parseint parses args[i] as int64, an error is replied if failed
+func (x *cmdctx) parseint(i int) (int64, bool) {
+	n, err := strconv.ParseInt(x.args[i], 10, 64)
+	if err != nil {
+		x.err(errNotInt)
+		return 0, false
+	}
+	return n, true
+}
+
+// parsefloat parses args[i] as float64, an error is replied if failed
+func (x *cmdctx) parsefloat(i int) (float64, bool) {
+	f, err := strconv.ParseFloat(x.args[i], 64)
+	if err != nil {
+		x.err(errNotFloat)
+		return 0, false
+	}
+	return f, true
+}
+
+func sorted(m map[string]struct{}) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,188 @@
+package redistest
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+)
+
+// do runs a command and returns the reply in a comparable form:
+// string for strings, int64 for integers, nil for nil, error for errors and []interface{} for arrays
+func do(t *testing.T, c *redisgo.Conn, cmd string, args ...interface{}) interface{} {
+	t.Helper()
+	reply, err := c.Do(cmd, args...)
+	if err != nil {
+		t.Fatal(cmd, err)
+	}
+	defer reply.Free()
+	return conv(reply)
+}
+
+func conv(r *redisgo.Reply) interface{} {
+	if r.IsNil() {
+		return nil
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if i, err := r.Integer(); err == nil {
+		return i
+	}
+	if b, err := r.Bytes(); err == nil {
+		return string(b)
+	}
+	aa, _ := r.Array()
+	if aa == nil {
+		return nil
+	}
+	ret := []interface{}{}
+	for i := range aa {
+		ret = append(ret, conv(&aa[i]))
+	}
+	return ret
+}
+
+func expect(t *testing.T, get, expect interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(get, expect) {
+		t.Fatalf("expect %#v, get %#v", expect, get)
+	}
+}
+
+func a(vv ...interface{}) []interface{} { return vv }
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetTime(time.Unix(1000, 0))
+
+	pool := redisgo.NewPool(s.Dial)
+	c, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn := c.Conn
+
+	// strings and ttl
+	expect(t, do(t, conn, "SET", "k", "v", "EX", 10), "OK")
+	expect(t, do(t, conn, "SET", "k", "v2", "NX"), nil)
+	expect(t, do(t, conn, "TTL", "k"), int64(10))
+	s.FastForward(9 * time.Second)
+	expect(t, do(t, conn, "GET", "k"), "v")
+	s.FastForward(time.Second)
+	expect(t, do(t, conn, "GET", "k"), nil)
+	expect(t, do(t, conn, "INCRBY", "n", 5), int64(5))
+	expect(t, do(t, conn, "INCRBYFLOAT", "n", 0.5), "5.5")
+	expect(t, do(t, conn, "MSET", "a", 1, "b", 2), "OK")
+	expect(t, do(t, conn, "MGET", "a", "x", "b"), a("1", nil, "2"))
+	expect(t, do(t, conn, "KEYS", "[ab]"), a("a", "b"))
+	expect(t, do(t, conn, "DEL", "a", "b", "x"), int64(2))
+
+	// hashes
+	expect(t, do(t, conn, "HSET", "h", "f1", "v1", "f2", "v2"), int64(2))
+	expect(t, do(t, conn, "HGETALL", "h"), a("f1", "v1", "f2", "v2"))
+	expect(t, do(t, conn, "HINCRBY", "h", "n", 3), int64(3))
+	expect(t, do(t, conn, "HMGET", "h", "f2", "x"), a("v2", nil))
+	expect(t, do(t, conn, "GET", "h").(error).Error(), errWrongType)
+
+	// lists
+	expect(t, do(t, conn, "RPUSH", "l", 1, 2, 3), int64(3))
+	expect(t, do(t, conn, "LPUSH", "l", 0), int64(4))
+	expect(t, do(t, conn, "LRANGE", "l", 1, -2), a("1", "2"))
+	expect(t, do(t, conn, "RPOP", "l", 2), a("3", "2"))
+	expect(t, do(t, conn, "LPOP", "l"), "0")
+
+	// sets
+	expect(t, do(t, conn, "SADD", "s1", "a", "b", "c"), int64(3))
+	expect(t, do(t, conn, "SADD", "s2", "b", "c", "d"), int64(3))
+	expect(t, do(t, conn, "SINTER", "s1", "s2"), a("b", "c"))
+	expect(t, do(t, conn, "SISMEMBER", "s1", "d"), int64(0))
+
+	// sorted sets
+	expect(t, do(t, conn, "ZADD", "z", 1, "a", 2, "b", 3, "c"), int64(3))
+	expect(t, do(t, conn, "ZINCRBY", "z", 10, "a"), "11")
+	expect(t, do(t, conn, "ZRANGE", "z", 0, -1, "WITHSCORES"), a("b", "2", "c", "3", "a", "11"))
+	expect(t, do(t, conn, "ZRANGEBYSCORE", "z", "(2", "+inf", "LIMIT", 0, 1), a("c"))
+	expect(t, do(t, conn, "ZREVRANK", "z", "a"), int64(0))
+	expect(t, do(t, conn, "ZSCORE", "z", "x"), nil)
+
+	// scan
+	for i := 0; i < 20; i++ {
+		do(t, conn, "SET", "scan:"+strconv.Itoa(i), i)
+	}
+	keys := map[string]bool{}
+	cursor := "0"
+	for {
+		r := do(t, conn, "SCAN", cursor, "MATCH", "scan:*", "COUNT", 7).([]interface{})
+		for _, k := range r[1].([]interface{}) {
+			keys[k.(string)] = true
+		}
+		if cursor = r[0].(string); cursor == "0" {
+			break
+		}
+	}
+	expect(t, len(keys), 20)
+
+	// SELECT
+	expect(t, do(t, conn, "SELECT", 1), "OK")
+	expect(t, do(t, conn, "DBSIZE"), int64(0))
+	expect(t, do(t, conn, "SELECT", 0), "OK")
+}
+
+func TestServerMulti(t *testing.T) {
+	s := NewUnstartedServer()
+	defer s.Close()
+	c0, _ := s.DialPipe(context.Background())
+	c1, _ := s.DialPipe(context.Background())
+	defer c0.Close()
+	defer c1.Close()
+
+	expect(t, do(t, c0, "MULTI"), "OK")
+	expect(t, do(t, c0, "SET", "k", 1), "QUEUED")
+	expect(t, do(t, c0, "INCR", "k"), "QUEUED")
+	expect(t, do(t, c0, "EXEC"), a("OK", int64(2)))
+
+	expect(t, do(t, c0, "WATCH", "k"), "OK")
+	expect(t, do(t, c1, "SET", "k", 5), "OK")
+	expect(t, do(t, c0, "MULTI"), "OK")
+	expect(t, do(t, c0, "INCR", "k"), "QUEUED")
+	expect(t, do(t, c0, "EXEC"), nil)
+	expect(t, do(t, c0, "GET", "k"), "5")
+
+	expect(t, do(t, c0, "MULTI"), "OK")
+	expect(t, do(t, c0, "NOTACOMMAND").(error) != nil, true)
+	if err := c0.DoNoReply("EXEC"); err == nil {
+		t.Fatal("expect EXECABORT")
+	}
+}
+
+func TestServerPubSub(t *testing.T) {
+	s := NewUnstartedServer()
+	defer s.Close()
+	sub, _ := s.DialPipe(context.Background())
+	pub, _ := s.DialPipe(context.Background())
+	defer sub.Close()
+	defer pub.Close()
+
+	expect(t, do(t, sub, "SUBSCRIBE", "ch"), a("subscribe", "ch", int64(1)))
+	expect(t, do(t, sub, "PSUBSCRIBE", "c*"), a("psubscribe", "c*", int64(2)))
+	expect(t, do(t, sub, "GET", "k").(error) != nil, true)
+	expect(t, do(t, pub, "PUBLISH", "ch", "hello"), int64(2))
+
+	reply := redisgo.NewReply()
+	defer reply.Free()
+	for _, msg := range []interface{}{
+		a("message", "ch", "hello"),
+		a("pmessage", "c*", "ch", "hello"),
+	} {
+		if err := sub.Recv(reply); err != nil {
+			t.Fatal(err)
+		}
+		expect(t, conv(reply), msg)
+	}
+}
@@ -0,0 +1,128 @@
+package redistest
+
+func init() {
+	register("SADD", -3, cmdSAdd)
+	register("SREM", -3, cmdSRem)
+	register("SMEMBERS", 2, cmdSMembers)
+	register("SISMEMBER", 3, cmdSIsMember)
+	register("SCARD", 2, cmdSCard)
+	register("SPOP", 2, cmdSPop)
+	register("SINTER", -2, cmdSetOp)
+	register("SUNION", -2, cmdSetOp)
+	register("SDIFF", -2, cmdSetOp)
+	register("SSCAN", -3, cmdSScan)
+}
+
+func cmdSAdd(x *cmdctx) {
+	st, ok := x.getset(x.args[0], true)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	for _, m := range x.args[1:] {
+		if _, exists := st[m]; !exists {
+			st[m] = struct{}{}
+			n++
+		}
+	}
+	x.db.touch(x.args[0])
+	x.integer(n)
+}
+
+func cmdSRem(x *cmdctx) {
+	st, ok := x.getset(x.args[0], false)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	for _, m := range x.args[1:] {
+		if _, exists := st[m]; exists {
+			delete(st, m)
+			n++
+		}
+	}
+	if n > 0 {
+		x.cleanup(x.args[0])
+	}
+	x.integer(n)
+}
+
+func cmdSMembers(x *cmdctx) {
+	st, ok := x.getset(x.args[0], false)
+	if ok {
+		x.array(sorted(st))
+	}
+}
+
+func cmdSIsMember(x *cmdctx) {
+	st, ok := x.getset(x.args[0], false)
+	if ok {
+		_, exists := st[x.args[1]]
+		x.boolean(exists)
+	}
+}
+
+func cmdSCard(x *cmdctx) {
+	st, ok := x.getset(x.args[0], false)
+	if ok {
+		x.integer(int64(len(st)))
+	}
+}
+
+// cmdSPop pops the smallest member for deterministic tests
+func cmdSPop(x *cmdctx) {
+	st, ok := x.getset(x.args[0], false)
+	if !ok {
+		return
+	}
+	if len(st) == 0 {
+		x.w.WriteNil()
+		return
+	}
+	m := sorted(st)[0]
+	delete(st, m)
+	x.cleanup(x.args[0])
+	x.bulk(m)
+}
+
+// cmdSetOp handles SINTER, SUNION and SDIFF
+func cmdSetOp(x *cmdctx) {
+	sets := make([]set, 0, len(x.args))
+	for _, k := range x.args {
+		st, ok := x.getset(k, false)
+		if !ok {
+			return
+		}
+		sets = append(sets, st)
+	}
+	ret := make(set)
+	for m := range sets[0] {
+		ret[m] = struct{}{}
+	}
+	for _, st := range sets[1:] {
+		switch x.name() {
+		case "SINTER":
+			for m := range ret {
+				if _, exists := st[m]; !exists {
+					delete(ret, m)
+				}
+			}
+		case "SUNION":
+			for m := range st {
+				ret[m] = struct{}{}
+			}
+		case "SDIFF":
+			for m := range st {
+				delete(ret, m)
+			}
+		}
+	}
+	x.array(sorted(ret))
+}
+
+func cmdSScan(x *cmdctx) {
+	st, ok := x.getset(x.args[0], false)
+	if ok {
+		x.scan(sorted(st), x.args[1:], nil, false)
+	}
+}
@@ -0,0 +1,277 @@
+package redistest
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	register("GET", 2, cmdGet)
+	register("SET", -3, cmdSet)
+	register("SETNX", 3, cmdSetNX)
+	register("SETEX", 4, cmdSetEX)
+	register("PSETEX", 4, cmdSetEX)
+	register("GETSET", 3, cmdGetSet)
+	register("GETDEL", 2, cmdGetDel)
+	register("MGET", -2, cmdMGet)
+	register("MSET", -3, cmdMSet)
+	register("INCR", 2, cmdIncr)
+	register("DECR", 2, cmdIncr)
+	register("INCRBY", 3, cmdIncr)
+	register("DECRBY", 3, cmdIncr)
+	register("INCRBYFLOAT", 3, cmdIncrByFloat)
+	register("APPEND", 3, cmdAppend)
+	register("STRLEN", 2, cmdStrlen)
+}
+
+func cmdGet(x *cmdctx) {
+	v, exists, ok := x.getstring(x.args[0])
+	if !ok {
+		return
+	}
+	if !exists {
+		x.w.WriteNil()
+		return
+	}
+	x.bulk(v)
+}
+
+// setstring sets a string value, the ttl is kept if keepttl is true
+func (x *cmdctx) setstring(key, v string, keepttl bool) {
+	e := x.db.get(x.now, key)
+	if keepttl && e != nil {
+		e.v = v
+		x.db.touch(key)
+		return
+	}
+	x.db.set(key, v)
+}
+
+// cmdSet handles SET key value [NX|XX] [GET] [EX s|PX ms|EXAT s|PXAT ms|KEEPTTL]
+func cmdSet(x *cmdctx) {
+	key, val := x.args[0], x.args[1]
+	var nx, xx, get, keepttl bool
+	var expireAt time.Time
+	for i := 2; i < len(x.args); i++ {
+		opt := strings.ToUpper(x.args[i])
+		switch opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepttl = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if i+1 >= len(x.args) {
+				x.err(errSyntax)
+				return
+			}
+			i++
+			n, ok := x.parseint(i)
+			if !ok {
+				return
+			}
+			if n <= 0 && (opt == "EX" || opt == "PX") {
+				x.err("ERR invalid expire time in 'set' command")
+				return
+			}
+			switch opt {
+			case "EX":
+				expireAt = x.now.Add(time.Duration(n) * time.Second)
+			case "PX":
+				expireAt = x.now.Add(time.Duration(n) * time.Millisecond)
+			case "EXAT":
+				expireAt = time.Unix(n, 0)
+			case "PXAT":
+				expireAt = time.Unix(0, n*int64(time.Millisecond))
+			}
+		default:
+			x.err(errSyntax)
+			return
+		}
+	}
+	if (nx && xx) || (keepttl && !expireAt.IsZero()) {
+		x.err(errSyntax)
+		return
+	}
+	e := x.db.get(x.now, key)
+	var old string
+	if get && e != nil {
+		var ok bool
+		if old, ok = e.v.(string); !ok {
+			x.err(errWrongType)
+			return
+		}
+	}
+	if (nx && e != nil) || (xx && e == nil) {
+		if get && e != nil {
+			x.bulk(old)
+		} else {
+			x.w.WriteNil()
+		}
+		return
+	}
+	x.setstring(key, val, keepttl)
+	if !expireAt.IsZero() {
+		x.db.m[key].expireAt = expireAt
+	}
+	switch {
+	case !get:
+		x.ok()
+	case e == nil:
+		x.w.WriteNil()
+	default:
+		x.bulk(old)
+	}
+}
+
+func cmdSetNX(x *cmdctx) {
+	if x.db.get(x.now, x.args[0]) != nil {
+		x.integer(0)
+		return
+	}
+	x.db.set(x.args[0], x.args[1])
+	x.integer(1)
+}
+
+func cmdSetEX(x *cmdctx) {
+	n, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	if n <= 0 {
+		x.err("ERR invalid expire time in '" + strings.ToLower(x.name()) + "' command")
+		return
+	}
+	d := time.Duration(n) * time.Second
+	if x.name() == "PSETEX" {
+		d = time.Duration(n) * time.Millisecond
+	}
+	x.db.set(x.args[0], x.args[2])
+	x.db.m[x.args[0]].expireAt = x.now.Add(d)
+	x.ok()
+}
+
+func cmdGetSet(x *cmdctx) {
+	v, exists, ok := x.getstring(x.args[0])
+	if !ok {
+		return
+	}
+	x.db.set(x.args[0], x.args[1])
+	if !exists {
+		x.w.WriteNil()
+		return
+	}
+	x.bulk(v)
+}
+
+func cmdGetDel(x *cmdctx) {
+	v, exists, ok := x.getstring(x.args[0])
+	if !ok {
+		return
+	}
+	if !exists {
+		x.w.WriteNil()
+		return
+	}
+	x.db.del(x.args[0])
+	x.bulk(v)
+}
+
+func cmdMGet(x *cmdctx) {
+	x.w.WriteArrayHeader(len(x.args))
+	for _, k := range x.args {
+		e := x.db.get(x.now, k)
+		if v, ok := e.value().(string); ok {
+			x.bulk(v)
+		} else {
+			x.w.WriteNil()
+		}
+	}
+}
+
+func cmdMSet(x *cmdctx) {
+	if len(x.args)%2 != 0 {
+		x.errargs()
+		return
+	}
+	for i := 0; i < len(x.args); i += 2 {
+		x.db.set(x.args[i], x.args[i+1])
+	}
+	x.ok()
+}
+
+// cmdIncr handles INCR, DECR, INCRBY and DECRBY
+func cmdIncr(x *cmdctx) {
+	delta := int64(1)
+	if len(x.args) > 1 {
+		var ok bool
+		if delta, ok = x.parseint(1); !ok {
+			return
+		}
+	}
+	if strings.HasPrefix(x.name(), "DECR") {
+		delta = -delta
+	}
+	v, exists, ok := x.getstring(x.args[0])
+	if !ok {
+		return
+	}
+	n := int64(0)
+	if exists {
+		var err error
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			x.err(errNotInt)
+			return
+		}
+	}
+	if (delta > 0 && n+delta < n) || (delta < 0 && n+delta > n) {
+		x.err("ERR increment or decrement would overflow")
+		return
+	}
+	n += delta
+	x.setstring(x.args[0], strconv.FormatInt(n, 10), true)
+	x.integer(n)
+}
+
+func cmdIncrByFloat(x *cmdctx) {
+	delta, ok := x.parsefloat(1)
+	if !ok {
+		return
+	}
+	v, exists, ok := x.getstring(x.args[0])
+	if !ok {
+		return
+	}
+	f := 0.0
+	if exists {
+		var err error
+		if f, err = strconv.ParseFloat(v, 64); err != nil {
+			x.err(errNotFloat)
+			return
+		}
+	}
+	f += delta
+	x.setstring(x.args[0], formatfloat(f), true)
+	x.float(f)
+}
+
+func cmdAppend(x *cmdctx) {
+	v, _, ok := x.getstring(x.args[0])
+	if !ok {
+		return
+	}
+	v += x.args[1]
+	x.setstring(x.args[0], v, true)
+	x.integer(int64(len(v)))
+}
+
+func cmdStrlen(x *cmdctx) {
+	v, _, ok := x.getstring(x.args[0])
+	if ok {
+		x.integer(int64(len(v)))
+	}
+}
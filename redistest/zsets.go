@@ -0,0 +1,494 @@
+package redistest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register("ZADD", -4, cmdZAdd)
+	register("ZINCRBY", 4, cmdZIncrBy)
+	register("ZSCORE", 3, cmdZScore)
+	register("ZMSCORE", -3, cmdZMScore)
+	register("ZREM", -3, cmdZRem)
+	register("ZCARD", 2, cmdZCard)
+	register("ZRANK", 3, cmdZRank)
+	register("ZREVRANK", 3, cmdZRank)
+	register("ZRANGE", -4, cmdZRange)
+	register("ZREVRANGE", -4, cmdZRange)
+	register("ZRANGEBYSCORE", -4, cmdZRangeByScore)
+	register("ZREVRANGEBYSCORE", -4, cmdZRangeByScore)
+	register("ZCOUNT", 4, cmdZCount)
+	register("ZREMRANGEBYSCORE", 4, cmdZRemRangeByScore)
+	register("ZREMRANGEBYRANK", 4, cmdZRemRangeByRank)
+	register("ZSCAN", -3, cmdZScan)
+}
+
+type zset struct {
+	scores map[string]float64
+}
+
+type zmember struct {
+	member string
+	score  float64
+}
+
+func newzset() *zset {
+	return &zset{scores: make(map[string]float64)}
+}
+
+func (z *zset) len() int {
+	return len(z.scores)
+}
+
+// sorted returns members ordered by score, then by member
+func (z *zset) sorted() []zmember {
+	ret := make([]zmember, 0, len(z.scores))
+	for m, s := range z.scores {
+		ret = append(ret, zmember{m, s})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].score != ret[j].score {
+			return ret[i].score < ret[j].score
+		}
+		return ret[i].member < ret[j].member
+	})
+	return ret
+}
+
+// rank returns the rank of member in ascending order, -1 if not exists
+func (z *zset) rank(member string) int {
+	for i, m := range z.sorted() {
+		if m.member == member {
+			return i
+		}
+	}
+	return -1
+}
+
+// scorebound represents min or max of ZRANGEBYSCORE like "(1.5" or "-inf"
+type scorebound struct {
+	v    float64
+	excl bool
+}
+
+func parsebound(s string) (b scorebound, ok bool) {
+	if strings.HasPrefix(s, "(") {
+		b.excl = true
+		s = s[1:]
+	}
+	switch strings.ToLower(s) {
+	case "-inf":
+		b.v = math.Inf(-1)
+	case "+inf", "inf":
+		b.v = math.Inf(1)
+	default:
+		var err error
+		if b.v, err = strconv.ParseFloat(s, 64); err != nil {
+			return b, false
+		}
+	}
+	return b, true
+}
+
+func inrange(score float64, min, max scorebound) bool {
+	if score < min.v || (min.excl && score == min.v) {
+		return false
+	}
+	if score > max.v || (max.excl && score == max.v) {
+		return false
+	}
+	return true
+}
+
+// cmdZAdd handles ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func cmdZAdd(x *cmdctx) {
+	var nx, xx, gt, lt, ch, incr bool
+	i := 1
+loop:
+	for ; i < len(x.args); i++ {
+		switch strings.ToUpper(x.args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break loop
+		}
+	}
+	pairs := x.args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 || (nx && xx) || (gt && lt) || (nx && (gt || lt)) ||
+		(incr && len(pairs) != 2) {
+		x.err(errSyntax)
+		return
+	}
+	scores := make([]float64, 0, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		f, err := strconv.ParseFloat(pairs[j], 64)
+		if err != nil {
+			x.err(errNotFloat)
+			return
+		}
+		scores = append(scores, f)
+	}
+	z, ok := x.getzset(x.args[0], !xx)
+	if !ok {
+		return
+	}
+	if z == nil {
+		if incr {
+			x.w.WriteNil()
+		} else {
+			x.integer(0)
+		}
+		return
+	}
+	added, changed := int64(0), int64(0)
+	for j := 0; j < len(pairs); j += 2 {
+		member, score := pairs[j+1], scores[j/2]
+		old, exists := z.scores[member]
+		if incr && exists {
+			score += old
+		}
+		if (nx && exists) || (xx && !exists) ||
+			(exists && ((gt && score <= old) || (lt && score >= old))) {
+			if incr {
+				x.cleanup(x.args[0])
+				x.w.WriteNil()
+				return
+			}
+			continue
+		}
+		z.scores[member] = score
+		if !exists {
+			added++
+		} else if score != old {
+			changed++
+		}
+		if incr {
+			x.cleanup(x.args[0])
+			x.float(score)
+			return
+		}
+	}
+	x.cleanup(x.args[0])
+	if ch {
+		added += changed
+	}
+	x.integer(added)
+}
+
+func cmdZIncrBy(x *cmdctx) {
+	delta, ok := x.parsefloat(1)
+	if !ok {
+		return
+	}
+	z, ok := x.getzset(x.args[0], true)
+	if !ok {
+		return
+	}
+	z.scores[x.args[2]] += delta
+	x.db.touch(x.args[0])
+	x.float(z.scores[x.args[2]])
+}
+
+func cmdZScore(x *cmdctx) {
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	if z == nil {
+		x.w.WriteNil()
+		return
+	}
+	if s, exists := z.scores[x.args[1]]; exists {
+		x.float(s)
+		return
+	}
+	x.w.WriteNil()
+}
+
+func cmdZMScore(x *cmdctx) {
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	x.w.WriteArrayHeader(len(x.args) - 1)
+	for _, m := range x.args[1:] {
+		if z == nil {
+			x.w.WriteNil()
+		} else if s, exists := z.scores[m]; exists {
+			x.float(s)
+		} else {
+			x.w.WriteNil()
+		}
+	}
+}
+
+func cmdZRem(x *cmdctx) {
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	for _, m := range x.args[1:] {
+		if z == nil {
+			break
+		}
+		if _, exists := z.scores[m]; exists {
+			delete(z.scores, m)
+			n++
+		}
+	}
+	if n > 0 {
+		x.cleanup(x.args[0])
+	}
+	x.integer(n)
+}
+
+func cmdZCard(x *cmdctx) {
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	if z == nil {
+		x.integer(0)
+		return
+	}
+	x.integer(int64(z.len()))
+}
+
+func cmdZRank(x *cmdctx) {
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	if z == nil {
+		x.w.WriteNil()
+		return
+	}
+	r := z.rank(x.args[1])
+	if r < 0 {
+		x.w.WriteNil()
+		return
+	}
+	if x.name() == "ZREVRANK" {
+		r = z.len() - 1 - r
+	}
+	x.integer(int64(r))
+}
+
+func (x *cmdctx) zmembers(mm []zmember, withscores bool) {
+	ret := make([]string, 0, 2*len(mm))
+	for _, m := range mm {
+		ret = append(ret, m.member)
+		if withscores {
+			ret = append(ret, formatfloat(m.score))
+		}
+	}
+	x.array(ret)
+}
+
+func reverse(mm []zmember) {
+	for i, j := 0, len(mm)-1; i < j; i, j = i+1, j-1 {
+		mm[i], mm[j] = mm[j], mm[i]
+	}
+}
+
+// cmdZRange handles ZRANGE and ZREVRANGE by rank with WITHSCORES
+func cmdZRange(x *cmdctx) {
+	start, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	stop, ok := x.parseint(2)
+	if !ok {
+		return
+	}
+	withscores := false
+	for _, opt := range x.args[3:] {
+		if strings.ToUpper(opt) != "WITHSCORES" {
+			x.err(errSyntax)
+			return
+		}
+		withscores = true
+	}
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	if z == nil {
+		x.array(nil)
+		return
+	}
+	mm := z.sorted()
+	if x.name() == "ZREVRANGE" {
+		reverse(mm)
+	}
+	i, j := normrange(start, stop, len(mm))
+	x.zmembers(mm[i:j], withscores)
+}
+
+// cmdZRangeByScore handles ZRANGEBYSCORE key min max and ZREVRANGEBYSCORE key max min
+// with WITHSCORES and LIMIT offset count
+func cmdZRangeByScore(x *cmdctx) {
+	rev := x.name() == "ZREVRANGEBYSCORE"
+	min, ok1 := parsebound(x.args[1])
+	max, ok2 := parsebound(x.args[2])
+	if rev {
+		min, max = max, min
+	}
+	if !ok1 || !ok2 {
+		x.err("ERR min or max is not a float")
+		return
+	}
+	withscores := false
+	offset, count := 0, -1
+	for i := 3; i < len(x.args); i++ {
+		switch strings.ToUpper(x.args[i]) {
+		case "WITHSCORES":
+			withscores = true
+		case "LIMIT":
+			if i+2 >= len(x.args) {
+				x.err(errSyntax)
+				return
+			}
+			o, ok := x.parseint(i + 1)
+			if !ok {
+				return
+			}
+			c, ok := x.parseint(i + 2)
+			if !ok {
+				return
+			}
+			offset, count = int(o), int(c)
+			i += 2
+		default:
+			x.err(errSyntax)
+			return
+		}
+	}
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	if z == nil {
+		x.array(nil)
+		return
+	}
+	mm := z.sorted()
+	if rev {
+		reverse(mm)
+	}
+	var ret []zmember
+	for _, m := range mm {
+		if !inrange(m.score, min, max) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if count >= 0 && len(ret) >= count {
+			break
+		}
+		ret = append(ret, m)
+	}
+	x.zmembers(ret, withscores)
+}
+
+func cmdZCount(x *cmdctx) {
+	min, ok1 := parsebound(x.args[1])
+	max, ok2 := parsebound(x.args[2])
+	if !ok1 || !ok2 {
+		x.err("ERR min or max is not a float")
+		return
+	}
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	if z != nil {
+		for _, s := range z.scores {
+			if inrange(s, min, max) {
+				n++
+			}
+		}
+	}
+	x.integer(n)
+}
+
+func cmdZRemRangeByScore(x *cmdctx) {
+	min, ok1 := parsebound(x.args[1])
+	max, ok2 := parsebound(x.args[2])
+	if !ok1 || !ok2 {
+		x.err("ERR min or max is not a float")
+		return
+	}
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	if z != nil {
+		for m, s := range z.scores {
+			if inrange(s, min, max) {
+				delete(z.scores, m)
+				n++
+			}
+		}
+		x.cleanup(x.args[0])
+	}
+	x.integer(n)
+}
+
+func cmdZRemRangeByRank(x *cmdctx) {
+	start, ok := x.parseint(1)
+	if !ok {
+		return
+	}
+	stop, ok := x.parseint(2)
+	if !ok {
+		return
+	}
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	n := int64(0)
+	if z != nil {
+		mm := z.sorted()
+		i, j := normrange(start, stop, len(mm))
+		for _, m := range mm[i:j] {
+			delete(z.scores, m.member)
+			n++
+		}
+		x.cleanup(x.args[0])
+	}
+	x.integer(n)
+}
+
+func cmdZScan(x *cmdctx) {
+	z, ok := x.getzset(x.args[0], false)
+	if !ok {
+		return
+	}
+	var members []string
+	if z != nil {
+		for _, m := range z.sorted() {
+			members = append(members, m.member)
+		}
+	}
+	x.scan(members, x.args[1:], func(m string) string { return formatfloat(z.scores[m]) }, false)
+}
@@ -0,0 +1,142 @@
+package redisgo_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+func TestDoTyped(t *testing.T) {
+	s := redistest.NewUnstartedServer()
+	defer s.Close()
+	conn := redisgo.NewConn(s.Pipe())
+	defer conn.Close()
+
+	conn.DoNoReply("ZADD", "z", 1.5, "a")
+	if v, err := conn.DoFloat64("ZSCORE", "z", "a"); err != nil || v != 1.5 {
+		t.Fatal(v, err)
+	}
+	if _, err := conn.DoFloat64("ZSCORE", "z", "x"); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+	conn.DoNoReply("SET", "k", "v")
+	if v, err := conn.DoString("GET", "k"); err != nil || v != "v" {
+		t.Fatal(v, err)
+	}
+	conn.DoNoReply("HSET", "h", "f1", "v1", "f2", "v2")
+	if v, err := conn.DoStringMap("HGETALL", "h"); err != nil || !reflect.DeepEqual(v, map[string]string{"f1": "v1", "f2": "v2"}) {
+		t.Fatal(v, err)
+	}
+	if v, err := conn.DoStringMap("HGETALL", "x"); err != nil || len(v) != 0 {
+		t.Fatal(v, err)
+	}
+}
+
+func TestDoAs(t *testing.T) {
+	s := redistest.NewUnstartedServer()
+	defer s.Close()
+	conn := redisgo.NewConn(s.Pipe())
+	defer conn.Close()
+
+	conn.DoNoReply("RPUSH", "l", 1, 2, 3)
+	if v, err := redisgo.DoAs[[]int](conn, "LRANGE", "l", 0, -1); err != nil || !reflect.DeepEqual(v, []int{1, 2, 3}) {
+		t.Fatal(v, err)
+	}
+	conn.DoNoReply("HSET", "h", "name", "x", "age", 3)
+	type user struct {
+		Name string `redis:"name"`
+		Age  int    `redis:"age"`
+	}
+	if v, err := redisgo.DoAs[user](conn, "HGETALL", "h"); err != nil || v != (user{"x", 3}) {
+		t.Fatal(v, err)
+	}
+	if v, err := redisgo.DoAs[*string](conn, "GET", "x"); err != nil || v != nil {
+		t.Fatal(v, err)
+	}
+	if _, err := redisgo.DoAs[string](conn, "GET", "x"); err != redisgo.ErrNil {
+		t.Fatal(err)
+	}
+	_, err := redisgo.DoAs[[]int](conn, "HMGET", "h", "age", "name")
+	var de *redisgo.DecodeError
+	if !errors.As(err, &de) || de.Path != "[1]" {
+		t.Fatal(err)
+	}
+
+	reply, _ := conn.Do("HMGET", "h", "age", "name", "x")
+	defer reply.Free()
+	var age int
+	var name string
+	var x *string
+	if err := reply.Scan(&age, &name, &x); err != nil || age != 3 || name != "x" || x != nil {
+		t.Fatal(age, name, x, err)
+	}
+}
+
+func TestStruct(t *testing.T) {
+	s := redistest.NewUnstartedServer()
+	defer s.Close()
+	conn := redisgo.NewConn(s.Pipe())
+	defer conn.Close()
+
+	type user struct {
+		Name    string        `redis:"name"`
+		Age     int           `redis:"age,omitempty"`
+		TTL     time.Duration `redis:"ttl"`
+		Created time.Time     `redis:"created"`
+	}
+	u := user{Name: "x", TTL: time.Minute, Created: time.Unix(1000, 0).UTC()}
+	if err := conn.DoNoReply("HSET", redisgo.Args{"user:1"}.FromStruct(&u)...); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.Do("HGETALL", "user:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reply.Free()
+	var got user
+	if err := reply.ScanStruct(&got); err != nil || got != u {
+		t.Fatal(got, err)
+	}
+	if n, _ := conn.DoInteger("HEXISTS", "user:1", "age"); n != 0 {
+		t.Fatal("age not omitted")
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	m.Expect("BLPOP", "l", 1).Return([]string{"l", "v"}).Delay(50 * time.Millisecond)
+	m.Expect("GET", "k").Return("v")
+	m.Expect("BLPOP", "l", 0).Return([]string{"l", "v"}).Delay(50 * time.Millisecond)
+	m.Expect("PING").Return(redistest.Status("PONG")).Delay(50 * time.Millisecond)
+	conn := redisgo.NewConn(m.Conn(), redisgo.WithReadTimeout(20*time.Millisecond))
+	defer conn.Close()
+
+	reply, err := conn.DoTimeout(time.Second, "BLPOP", "l", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss, err := reply.Strings(); err != nil || len(ss) != 2 || ss[1] != "v" {
+		t.Fatal(ss, err)
+	}
+	reply.Free()
+
+	// no timeout, the deadline set by the last Do must not fire
+	if _, err := conn.DoBytes("GET", "k"); err != nil {
+		t.Fatal(err)
+	}
+	reply, err = conn.DoTimeout(0, "BLPOP", "l", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply.Free()
+
+	// the read timeout of Conn is used again
+	if _, err := conn.Do("PING"); err == nil {
+		t.Fatal("expect timeout")
+	}
+}
@@ -0,0 +1,306 @@
+package resp
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	cmdbufsz = 1024
+
+	// []byte args larger than bigargsz are not copied into command.buf,
+	// they are written with vectored io instead
+	bigargsz = 16 * 1024
+)
+
+type command struct {
+	n   int
+	buf []byte
+	tmp [1 + 24 + 2]byte // '*' or '$' + max-float-len + CRLF
+	arg []byte           // for encoding Arg and time.Time
+	err error            // the first err of Args
+
+	big []bigarg
+	vec net.Buffers
+}
+
+// bigarg represents an arg which is not copied into command.buf.
+// the data of it should be written at buf[off], before the trailing CRLF.
+type bigarg struct {
+	off int
+	b   []byte
+	r   io.Reader
+	n   int64
+}
+
+// Arg is implemented by types which encode themselves as a command arg.
+// AppendRedisArg appends the arg to buf and returns the extended buffer,
+// buf is reused by the encoder, so that it doesn't allocate for every arg.
+type Arg interface {
+	AppendRedisArg(buf []byte) []byte
+}
+
+// StreamArg represents a sized reader arg, see Stream
+type StreamArg struct {
+	r io.Reader
+	n int64
+}
+
+// Stream returns an arg which copies exactly n bytes from r to redis
+// without buffering the whole value in memory.
+// Encoder.WriteCommand returns error if r returns less than n bytes.
+func Stream(r io.Reader, n int64) StreamArg {
+	return StreamArg{r: r, n: n}
+}
+
+var commandPool = sync.Pool{
+	New: func() interface{} {
+		return &command{buf: make([]byte, 0, cmdbufsz)}
+	},
+}
+
+func (c *command) Reset(cmd string) *command {
+	c.n = 0
+	c.buf = c.buf[:0]
+	c.err = nil
+	c.resetbig()
+	c.appends(cmd)
+	return c
+}
+
+func (c *command) resetbig() {
+	for i := range c.big {
+		c.big[i] = bigarg{} // remove ref for gc friendly
+	}
+	c.big = c.big[:0]
+}
+
+// Vectored returns true if the command contains args which are not in buf
+func (c *command) Vectored() bool {
+	return len(c.big) > 0
+}
+
+func (c *command) header() []byte {
+	if c.n == 0 {
+		panic("command without args")
+	}
+	p := append(c.tmp[:0], '*')
+	p = strconv.AppendInt(p, int64(c.n), 10)
+	p = append(p, CR, LF)
+	return p
+}
+
+func (c *command) Dump(w io.Writer) (err error) {
+	_, err = w.Write(c.header())
+	if err != nil {
+		return
+	}
+	off := 0
+	for _, a := range c.big {
+		if _, err = w.Write(c.buf[off:a.off]); err != nil {
+			return
+		}
+		off = a.off
+		if a.r != nil {
+			err = copyn(w, a.r, a.n)
+		} else {
+			_, err = w.Write(a.b)
+		}
+		if err != nil {
+			return
+		}
+	}
+	_, err = w.Write(c.buf[off:])
+	return
+}
+
+// Writev writes the command to w.
+// []byte args are written with net.Buffers which uses writev if w is a net.Conn,
+// and StreamArg is copied from its reader to w directly.
+// It must be called with an empty write buffer, or the data will be out of order.
+func (c *command) Writev(w io.Writer) (err error) {
+	c.vec = append(c.vec[:0], c.header())
+	off := 0
+	for _, a := range c.big {
+		c.vec = append(c.vec, c.buf[off:a.off])
+		off = a.off
+		if a.r == nil {
+			c.vec = append(c.vec, a.b)
+			continue
+		}
+		if err = c.flushvec(w); err != nil {
+			return
+		}
+		if err = copyn(w, a.r, a.n); err != nil {
+			return
+		}
+	}
+	c.vec = append(c.vec, c.buf[off:])
+	return c.flushvec(w)
+}
+
+func (c *command) flushvec(w io.Writer) error {
+	vec := c.vec // WriteTo consumes the slice
+	_, err := vec.WriteTo(w)
+	for i := range c.vec {
+		c.vec[i] = nil
+	}
+	c.vec = c.vec[:0]
+	return err
+}
+
+func copyn(w io.Writer, r io.Reader, n int64) error {
+	written, err := io.CopyN(w, r, n)
+	if written < n && err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (c *command) appendi(i int64) {
+	c.appends(ss(strconv.AppendInt(c.tmp[:0], i, 10)))
+}
+
+func (c *command) appendf(f float64) {
+	c.appends(ss(strconv.AppendFloat(c.tmp[:0], f, 'g', -1, 64)))
+}
+
+func (c *command) appends(s string) {
+	c.buf = append(c.buf, '$')
+	c.buf = strconv.AppendInt(c.buf, int64(len(s)), 10)
+	c.buf = append(c.buf, CR, LF)
+	c.buf = append(c.buf, s...)
+	c.buf = append(c.buf, CR, LF)
+	c.n++
+}
+
+func (c *command) appendb(b []byte) {
+	if len(b) > bigargsz {
+		c.appendbig(b, nil, int64(len(b)))
+	} else {
+		c.appends(ss(b))
+	}
+}
+
+func (c *command) appendbig(b []byte, r io.Reader, n int64) {
+	c.buf = append(c.buf, '$')
+	c.buf = strconv.AppendInt(c.buf, n, 10)
+	c.buf = append(c.buf, CR, LF)
+	c.big = append(c.big, bigarg{off: len(c.buf), b: b, r: r, n: n})
+	c.buf = append(c.buf, CR, LF)
+	c.n++
+}
+
+// Args append args to the command.
+// type must be one of:
+// int, int8, int16, int32, int64
+// uint, uint8, uint16, uint32, uint64
+// float32, float64, bool (1 or 0)
+// []byte, string, []string, []int64, [][]byte
+// map[string]string (key, value pairs in unspecified order)
+// time.Time (RFC 3339 with nanoseconds), time.Duration (integer nanoseconds, use Milliseconds() for PX and so on)
+// StreamArg, Arg, encoding.TextMarshaler, encoding.BinaryMarshaler, fmt.Stringer
+// Err returns ErrInvalidArgType for other types, or wrapping the err of marshalers.
+func (c *command) Args(aa ...interface{}) *command {
+	for _, a := range aa {
+		if c.err != nil {
+			return c
+		}
+		switch v := a.(type) {
+		case int:
+			c.appendi(int64(v))
+		case int8:
+			c.appendi(int64(v))
+		case int16:
+			c.appendi(int64(v))
+		case int32:
+			c.appendi(int64(v))
+		case int64:
+			c.appendi(int64(v))
+		case uint:
+			c.appendi(int64(v))
+		case uint8:
+			c.appendi(int64(v))
+		case uint16:
+			c.appendi(int64(v))
+		case uint32:
+			c.appendi(int64(v))
+		case uint64:
+			c.appendi(int64(v))
+		case float32:
+			c.appendf(float64(v))
+		case float64:
+			c.appendf(float64(v))
+		case []byte:
+			c.appendb(v)
+		case StreamArg:
+			if v.r == nil || v.n < 0 {
+				c.err = ErrInvalidArgType
+				return c
+			}
+			c.appendbig(nil, v.r, v.n)
+		case string:
+			c.appends(v)
+		case []string:
+			for _, s := range v {
+				c.appends(s)
+			}
+		case Arg:
+			c.arg = v.AppendRedisArg(c.arg[:0])
+			c.appends(ss(c.arg))
+		case bool:
+			if v {
+				c.appends("1")
+			} else {
+				c.appends("0")
+			}
+		case time.Time:
+			c.arg = v.AppendFormat(c.arg[:0], time.RFC3339Nano)
+			c.appends(ss(c.arg))
+		case time.Duration:
+			c.appendi(int64(v))
+		case []int64:
+			for _, i := range v {
+				c.appendi(i)
+			}
+		case [][]byte:
+			for _, b := range v {
+				c.appendb(b)
+			}
+		case map[string]string:
+			for k, s := range v {
+				c.appends(k)
+				c.appends(s)
+			}
+		case encoding.TextMarshaler:
+			b, err := v.MarshalText()
+			if err != nil {
+				c.err = fmt.Errorf("%w: %T: %w", ErrInvalidArgType, a, err)
+				return c
+			}
+			c.appendb(b)
+		case encoding.BinaryMarshaler:
+			b, err := v.MarshalBinary()
+			if err != nil {
+				c.err = fmt.Errorf("%w: %T: %w", ErrInvalidArgType, a, err)
+				return c
+			}
+			c.appendb(b)
+		case fmt.Stringer:
+			c.appends(v.String())
+		default:
+			c.err = ErrInvalidArgType
+		}
+	}
+	return c
+}
+
+// Err returns the first err of Args
+func (c *command) Err() error {
+	return c.err
+}
@@ -0,0 +1,168 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tstr(s string) string {
+	b := make([]byte, 0, 30+len(s))
+	b = append(b, '$')
+	b = strconv.AppendInt(b, int64(len(s)), 10)
+	b = append(b, CRLF...)
+	b = append(b, s...)
+	b = append(b, CRLF...)
+	return string(b)
+}
+
+func tcmd(aa ...string) string {
+	s := strings.Join(aa, "")
+	b := make([]byte, 0, 30+len(s))
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(aa)), 10)
+	b = append(b, CRLF...)
+	b = append(b, s...)
+	return string(b)
+}
+
+func TestCommand(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := commandPool.Get().(*command)
+	c.Reset("CMD").Args("a0", []byte("a1")).Args(uint(1)).Dump(buf)
+	if s := tcmd(tstr("CMD"), tstr("a0"), tstr("a1"), tstr("1")); s != buf.String() {
+		t.Fatal("expect:\n", s, "get:\n", buf.String())
+	}
+
+	buf.Reset()
+
+	c.Reset("CMD").Args([]string{"a0", "a1"}).Args(uint(1)).Dump(buf)
+	if s := tcmd(tstr("CMD"), tstr("a0"), tstr("a1"), tstr("1")); s != buf.String() {
+		t.Fatal("expect:\n", s, "get:\n", buf.String())
+	}
+}
+
+func TestCommandBigArgs(t *testing.T) {
+	big := strings.Repeat("x", bigargsz+1)
+	expect := tcmd(tstr("SET"), tstr("k0"), tstr(big), tstr("k1"), tstr("stream"), tstr("1"))
+
+	c := commandPool.Get().(*command)
+	reset := func() *command {
+		return c.Reset("SET").Args("k0", []byte(big), "k1",
+			Stream(strings.NewReader("stream"), 6), 1)
+	}
+	if !reset().Vectored() {
+		t.Fatal("not vectored")
+	}
+
+	buf := new(bytes.Buffer)
+	if err := reset().Dump(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expect {
+		t.Fatal("dump not equal")
+	}
+
+	buf.Reset()
+	if err := reset().Writev(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expect {
+		t.Fatal("writev not equal")
+	}
+
+	buf.Reset()
+	c.Reset("SET").Args("k", Stream(strings.NewReader("short"), 6))
+	if err := c.Writev(buf); err != io.ErrUnexpectedEOF {
+		t.Fatal(err)
+	}
+}
+
+// point is a custom Arg
+type point struct{ x, y int64 }
+
+func (p *point) AppendRedisArg(buf []byte) []byte {
+	buf = strconv.AppendInt(buf, p.x, 10)
+	buf = append(buf, ',')
+	return strconv.AppendInt(buf, p.y, 10)
+}
+
+type textarg struct{ err error }
+
+func (a textarg) MarshalText() ([]byte, error) { return []byte("text"), a.err }
+
+type binarg struct{}
+
+func (binarg) MarshalBinary() ([]byte, error) { return []byte{0, 1}, nil }
+
+type strarg struct{}
+
+func (strarg) String() string { return "str" }
+
+func TestCommandArgs(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := commandPool.Get().(*command)
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	c.Reset("CMD").Args(&point{1, -2}, true, false, tm, 1500*time.Millisecond,
+		[]int64{3, 4}, [][]byte{[]byte("a"), nil}, map[string]string{"k": "v"},
+		textarg{}, binarg{}, strarg{})
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+	c.Dump(buf)
+	expect := tcmd(tstr("CMD"), tstr("1,-2"), tstr("1"), tstr("0"), tstr("2024-01-02T03:04:05.000000006Z"),
+		tstr("1500000000"), tstr("3"), tstr("4"), tstr("a"), tstr(""), tstr("k"), tstr("v"),
+		tstr("text"), tstr("\x00\x01"), tstr("str"))
+	if buf.String() != expect {
+		t.Fatal("expect:\n", expect, "get:\n", buf.String())
+	}
+
+	if err := c.Reset("CMD").Args(struct{}{}).Err(); err != ErrInvalidArgType {
+		t.Fatal(err)
+	}
+	if err := c.Reset("CMD").Args(Stream(nil, 1)).Err(); err != ErrInvalidArgType {
+		t.Fatal(err)
+	}
+	errtext := errors.New("text err")
+	err := c.Reset("CMD").Args(textarg{errtext}).Err()
+	if !errors.Is(err, ErrInvalidArgType) || !errors.Is(err, errtext) {
+		t.Fatal(err)
+	}
+
+	// nothing written
+	e := NewEncoder(buf)
+	buf.Reset()
+	if err := e.WriteCommand("SET", "k", textarg{errtext}); !errors.Is(err, errtext) || e.Buffered() != 0 {
+		t.Fatal(err, e.Buffered())
+	}
+}
+
+func TestCommandArgAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items randomly under -race")
+	}
+	e := NewEncoder(io.Discard)
+	args := []interface{}{"k", &point{1, 2}}
+	n := testing.AllocsPerRun(100, func() {
+		e.WriteCommand("SET", args...)
+		e.Flush()
+	})
+	if n != 0 {
+		t.Fatal(n)
+	}
+}
+
+func BenchmarkCommand(b *testing.B) {
+	b.ReportAllocs()
+	c := commandPool.Get().(*command)
+	for i := 0; i < b.N; i++ {
+		cmd := "GET"
+		a1 := "key"
+		a2 := 1
+		c.Reset(cmd).Args(a1).Args(a2)
+	}
+}
@@ -0,0 +1,333 @@
+package resp
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"unsafe"
+)
+
+const (
+	defaultReadBufferSize = 2048
+
+	defaultMaxBulkLen  = 512 * 1024 * 1024 // same as proto-max-bulk-len of redis
+	defaultMaxArrayLen = 16 * 1024 * 1024  // elements take memory far more than their bytes of input
+	defaultMaxDepth    = 64
+	defaultMaxLineLen  = 64 * 1024 // same as PROTO_INLINE_MAX_SIZE of redis
+)
+
+// Decoder reads and decodes RESP values from an input stream.
+// bytes of the decoded Reply are never overwritten by later calls of Decode.
+type Decoder struct {
+	br *reader
+
+	maxbulklen  int
+	maxarraylen int
+	maxdepth    int
+
+	stack  []frame
+	attr   Reply // for discarding attributes
+	inattr bool
+}
+
+// frame represents an array reply being read
+type frame struct {
+	r *Reply
+	n int // number of elements
+}
+
+// NewDecoder returns a new decoder that reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderSize(r, defaultReadBufferSize)
+}
+
+// NewDecoderSize returns a new decoder with read buffer of at least size sz
+func NewDecoderSize(r io.Reader, sz int) *Decoder {
+	d := &Decoder{
+		br:          newReader(r, sz),
+		maxbulklen:  defaultMaxBulkLen,
+		maxarraylen: defaultMaxArrayLen,
+		maxdepth:    defaultMaxDepth,
+	}
+	d.br.maxline = defaultMaxLineLen
+	return d
+}
+
+// SetMaxBulkLen limits the length of a bulk string, default: 512MB.
+func (d *Decoder) SetMaxBulkLen(n int) { d.maxbulklen = n }
+
+// SetMaxArrayLen limits the number of elements of an aggregate type, default: 16M.
+func (d *Decoder) SetMaxArrayLen(n int) { d.maxarraylen = n }
+
+// SetMaxDepth limits the nesting depth of aggregate types, default: 64.
+func (d *Decoder) SetMaxDepth(n int) { d.maxdepth = n }
+
+// SetMaxLineLen limits the length of a line like a type header, a simple string or an inline command,
+// it's not limited if n <= 0. default: 64KB.
+func (d *Decoder) SetMaxLineLen(n int) { d.br.maxline = n }
+
+// Buffered returns the number of bytes which are read but not decoded yet
+func (d *Decoder) Buffered() int {
+	return d.br.buffered()
+}
+
+// InputOffset returns the number of bytes decoded from the input stream
+func (d *Decoder) InputOffset() int64 {
+	return d.br.n - int64(d.br.buffered())
+}
+
+// Decode reads the next RESP value into r.
+// It returns *ProtocolError if the input is not valid RESP or exceeds the limits,
+// and returns io.EOF if no more input is available.
+// RESP3 Attributes are read and discarded.
+func (d *Decoder) Decode(r *Reply) error {
+	r.Reset()
+	stack := d.stack[:0]
+	defer func() {
+		for i := range stack {
+			stack[i] = frame{} // remove ref for gc friendly
+		}
+		d.stack = stack[:0]
+	}()
+	for {
+		n, err := d.readone(r, len(stack))
+		if err != nil {
+			return err
+		}
+		if n > 0 { // non-empty aggregate type, reads elements
+			stack = append(stack, frame{r: r, n: n})
+			r = r.next()
+			continue
+		}
+		for len(stack) > 0 {
+			f := &stack[len(stack)-1]
+			if len(f.r.array) < f.n {
+				r = f.r.next()
+				break
+			}
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			return nil
+		}
+	}
+}
+
+// readone reads a value which is not an aggregate type or the header of an aggregate type.
+// it returns the number of elements which are not read yet.
+// depth is the number of aggregate types r nested in.
+func (d *Decoder) readone(r *Reply, depth int) (n int, err error) {
+	var line []byte
+	for {
+		line, err = d.br.Readline()
+		if err != nil {
+			return
+		}
+		line = line[:len(line)-2] // remove CRLF
+		if len(line) == 0 {
+			err = protoerr("empty line", line)
+			return
+		}
+		if line[0] != '|' {
+			break
+		}
+		// Attributes, discarded in a loop instead of recursion for consecutive ones,
+		// then reads the value which they belong to
+		if d.inattr {
+			return 0, protoerr("nested attribute", line)
+		}
+		if err = d.skipattr(line, depth); err != nil {
+			return 0, err
+		}
+	}
+	t := line[0]
+	b := line[1:]
+	switch t {
+	case '+': // Simple Strings
+		r.b = b
+		r.t = TypeSimpleString
+	case '-': // Simple Errors
+		r.t = TypeError
+		r.err = *(*RedisErr)(unsafe.Pointer(&b))
+	case ':': // Integers
+		r.i, err = strconv.ParseInt(ss(b), 10, 64)
+		if err != nil {
+			return 0, protoerr("invalid integer", line)
+		}
+		r.t = TypeInteger
+	case '$', '!', '=': // Bulk Strings, Blob Errors, Verbatim Strings
+		n, err = d.readlen(line, d.maxbulklen)
+		if err != nil || n < 0 {
+			r.t = TypeNil
+			return 0, err
+		}
+		r.b, err = d.br.Read(n + 2)
+		if err != nil {
+			return 0, err
+		}
+		if r.b[n] != CR || r.b[n+1] != LF {
+			return 0, protoerr("bulk string not ending with CRLF", line)
+		}
+		r.b = r.b[:n:n]
+		switch t {
+		case '$':
+			r.t = TypeBulkString
+		case '!':
+			r.t = TypeError
+			r.err = RedisErr(r.b)
+			r.b = nil
+		case '=':
+			if n < 4 || r.b[3] != ':' {
+				return 0, protoerr("invalid verbatim string", line)
+			}
+			r.t = TypeVerbatim
+		}
+		n = 0
+	case '*', '~', '>', '%': // Arrays, Sets, Pushes, Maps
+		n, err = d.readlen(line, d.maxarraylen)
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 {
+			if t != '*' {
+				return 0, protoerr("negative length", line)
+			}
+			r.t = TypeNilArray
+			return 0, nil
+		}
+		if n > 0 && depth >= d.maxdepth {
+			return 0, protoerr("nesting depth exceeds limit", line)
+		}
+		switch t {
+		case '*':
+			r.t = TypeArray
+		case '~':
+			r.t = TypeSet
+		case '>':
+			r.t = TypePush
+		case '%':
+			if n > d.maxarraylen/2 {
+				return 0, protoerr("length exceeds limit", line)
+			}
+			r.t = TypeMap
+			n *= 2
+		}
+		if r.array == nil {
+			// not trusting n, the array grows as elements arrive
+			r.array = make([]Reply, 0, minint(n, 1024))
+		}
+	case '_': // Null
+		if len(b) != 0 {
+			return 0, protoerr("invalid null", line)
+		}
+		r.t = TypeNil
+	case ',': // Doubles
+		if _, err = strconv.ParseFloat(ss(b), 64); err != nil {
+			return 0, protoerr("invalid double", line)
+		}
+		r.b = b
+		r.t = TypeDouble
+	case '#': // Booleans
+		if len(b) != 1 || (b[0] != 't' && b[0] != 'f') {
+			return 0, protoerr("invalid boolean", line)
+		}
+		r.i = 0
+		if b[0] == 't' {
+			r.i = 1
+		}
+		r.t = TypeBool
+	case '(': // Big Numbers
+		if len(b) == 0 {
+			return 0, protoerr("invalid big number", line)
+		}
+		r.b = b
+		r.t = TypeBigNumber
+	default:
+		err = protoerr("unknown reply type", line)
+	}
+	return
+}
+
+// skipattr reads the attribute map of the header line and discards it
+func (d *Decoder) skipattr(line []byte, depth int) error {
+	n, err := d.readlen(line, d.maxarraylen/2)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return protoerr("negative length", line)
+	}
+	if depth >= d.maxdepth {
+		return protoerr("nesting depth exceeds limit", line)
+	}
+	saved := d.stack
+	d.stack = nil
+	d.inattr = true
+	defer func() {
+		d.stack = saved
+		d.inattr = false
+		d.attr.Reset()
+	}()
+	for i := 0; i < 2*n; i++ {
+		if err = d.Decode(&d.attr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readlen parses length of bulk strings or aggregate types, -1 is returned for nil
+func (d *Decoder) readlen(line []byte, max int) (int, error) {
+	n, err := strconv.Atoi(ss(line[1:]))
+	if err != nil {
+		return 0, protoerr("invalid length", line)
+	}
+	if n < -1 {
+		return 0, protoerr("negative length", line)
+	}
+	if n > max {
+		return 0, protoerr("length exceeds limit", line)
+	}
+	return n, nil
+}
+
+// DecodeCommand reads the next command into r, which is used by servers.
+// the command is either an array of bulk strings or an inline command like "PING\r\n",
+// r is an array of bulk strings after it returned, empty lines are skipped.
+func (d *Decoder) DecodeCommand(r *Reply) error {
+	for {
+		if d.br.buffered() == 0 {
+			if err := d.br.fillmore(); err != nil {
+				return err
+			}
+		}
+		if d.br.bytes()[0] == '*' {
+			if err := d.Decode(r); err != nil {
+				return err
+			}
+			if r.t != TypeArray || len(r.array) == 0 {
+				return protoerr("invalid command", nil)
+			}
+			for i := range r.array {
+				if r.array[i].t != TypeBulkString {
+					return protoerr("invalid command argument type "+r.array[i].t.String(), nil)
+				}
+			}
+			return nil
+		}
+		line, err := d.br.Readline()
+		if err != nil {
+			return err
+		}
+		r.Reset()
+		r.t = TypeArray
+		for _, b := range bytes.Fields(line) {
+			a := r.next()
+			a.t = TypeBulkString
+			a.b = b
+		}
+		if len(r.array) > 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package resp
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	e := NewEncoder(buf)
+	e.WriteSimpleString("OK")
+	e.WriteError("ERR x")
+	e.WriteInteger(-7)
+	e.WriteBulkString("bulk\r\n")
+	e.WriteNil()
+	e.WriteNilArray()
+	e.WriteArrayHeader(2)
+	e.WriteArrayHeader(0)
+	e.WriteBulk([]byte("a"))
+	e.WriteNull()
+	e.WriteDouble(1.5)
+	e.WriteDouble(math.Inf(-1))
+	e.WriteBool(true)
+	e.WriteBigNumber("12345678901234567890")
+	e.WriteBlobError("SYNTAX x\r\ny")
+	e.WriteVerbatim("txt", "hello")
+	e.WriteAttributeHeader(1)
+	e.WriteSimpleString("key")
+	e.WriteInteger(1)
+	e.WriteMapHeader(1)
+	e.WriteSimpleString("k")
+	e.WriteSetHeader(1)
+	e.WriteBool(false)
+	e.WritePushHeader(1)
+	e.WriteSimpleString("message")
+	e.WriteCommand("SET", "k", 1)
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	src := buf.String()
+
+	d := NewDecoder(strings.NewReader(src))
+	var rr []*Reply
+	for {
+		r := NewReply()
+		err := d.Decode(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr = append(rr, r)
+	}
+	types := []Type{TypeSimpleString, TypeError, TypeInteger, TypeBulkString, TypeNil,
+		TypeNilArray, TypeArray, TypeNil, TypeDouble, TypeDouble, TypeBool, TypeBigNumber,
+		TypeError, TypeVerbatim, TypeMap, TypePush, TypeArray}
+	if len(rr) != len(types) {
+		t.Fatal(len(rr), len(types))
+	}
+	for i, r := range rr {
+		if r.Type() != types[i] {
+			t.Fatal(i, r.Type(), types[i])
+		}
+	}
+	if b, _ := rr[3].Bytes(); string(b) != "bulk\r\n" {
+		t.Fatal(string(b))
+	}
+	if b, _ := rr[13].Bytes(); string(b) != "hello" {
+		t.Fatal(string(b))
+	}
+	if err := rr[12].Err(); err.Error() != "SYNTAX x\r\ny" {
+		t.Fatal(err)
+	}
+	if aa, _ := rr[14].Array(); len(aa) != 2 || aa[1].Type() != TypeSet {
+		t.Fatal(aa)
+	}
+
+	// encodes decoded replies, it must be the same as src except the attribute
+	buf.Reset()
+	for _, r := range rr {
+		if err := e.WriteReply(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e.Flush()
+	expect := strings.Replace(src, "|1\r\n+key\r\n:1\r\n", "", 1)
+	expect = strings.Replace(expect, "_\r\n", "$-1\r\n", 1) // RESP3 Null is the same as Null Bulk String
+	if buf.String() != expect {
+		t.Fatalf("expect:\n%q\nget:\n%q", expect, buf.String())
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	for _, s := range []string{
+		"$2000000000\r\n",
+		"*-2\r\n",
+		"%-1\r\n",
+		"$1\r\nab\r\n",
+		"*1\r\n*1\r\n*1\r\n:1\r\n",
+		"|1\r\n|1\r\n",
+		"#x\r\n",
+		"?\r\n",
+		"+" + strings.Repeat("x", 200) + "\r\n",
+		"+" + strings.Repeat("x", 5000),
+	} {
+		d := NewDecoder(strings.NewReader(s))
+		d.SetMaxBulkLen(100)
+		d.SetMaxDepth(2)
+		d.SetMaxLineLen(100)
+		err := d.Decode(NewReply())
+		if _, ok := err.(*ProtocolError); !ok {
+			t.Fatal(s, err)
+		}
+	}
+}
+
+func TestDecoderAttributes(t *testing.T) {
+	// consecutive attributes are discarded in a loop without growing the stack
+	s := strings.Repeat("|0\r\n", 1<<20) + "|1\r\n+k\r\n+v\r\n:1\r\n"
+	r := NewReply()
+	if err := NewDecoder(strings.NewReader(s)).Decode(r); err != nil || r.Type() != TypeInteger || r.i != 1 {
+		t.Fatal(r.Type(), err)
+	}
+
+	// the default limit of arrays
+	err := NewDecoder(strings.NewReader("*20000000\r\n")).Decode(r)
+	if _, ok := err.(*ProtocolError); !ok {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeCommandLineLimit(t *testing.T) {
+	// inline commands without CRLF are not buffered without bound
+	r := io.MultiReader(strings.NewReader("SET k "), infinite('x'))
+	err := NewDecoder(r).DecodeCommand(NewReply())
+	if _, ok := err.(*ProtocolError); !ok {
+		t.Fatal(err)
+	}
+
+	d := NewDecoder(strings.NewReader("PING " + strings.Repeat("x", 1000) + "\r\nPING\r\n"))
+	d.SetMaxLineLen(0)
+	reply := NewReply()
+	if err := d.DecodeCommand(reply); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// infinite is an endless reader of b
+type infinite byte
+
+func (b infinite) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(b)
+	}
+	return len(p), nil
+}
+
+func TestDecoderInputOffset(t *testing.T) {
+	s := "+OK\r\n$5\r\nhello\r\n*2\r\n:1\r\n:2\r\n"
+	d := NewDecoderSize(strings.NewReader(s), 1)
+	r := NewReply()
+	for _, off := range []int64{5, 16, 28} {
+		if err := d.Decode(r); err != nil {
+			t.Fatal(err)
+		}
+		if d.InputOffset() != off {
+			t.Fatal(off, d.InputOffset())
+		}
+	}
+}
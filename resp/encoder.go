@@ -0,0 +1,239 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+)
+
+const defaultWriteBufferSize = 2048
+
+// Encoder writes RESP values to an output stream.
+// values are buffered, Flush must be called to write them to the underlying writer.
+type Encoder struct {
+	w   io.Writer // for writing big args of commands
+	bw  *bufio.Writer
+	tmp [1 + 24 + 2]byte
+}
+
+// NewEncoder returns a new encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return NewEncoderSize(w, defaultWriteBufferSize)
+}
+
+// NewEncoderSize returns a new encoder with write buffer of at least size sz
+func NewEncoderSize(w io.Writer, sz int) *Encoder {
+	return &Encoder{w: w, bw: bufio.NewWriterSize(w, sz)}
+}
+
+// Buffered returns the number of bytes which are not flushed yet
+func (e *Encoder) Buffered() int {
+	return e.bw.Buffered()
+}
+
+// Flush writes any buffered data to the underlying writer
+func (e *Encoder) Flush() error {
+	return e.bw.Flush()
+}
+
+// WriteCommand writes a command as an array of bulk strings.
+// see the Args method of command for supported types, custom types can implement Arg.
+// ErrInvalidArgType or an err wrapping it is returned without writing anything if any of args is not supported.
+func (e *Encoder) WriteCommand(cmd string, args ...interface{}) (err error) {
+	cc := commandPool.Get().(*command)
+	if err = cc.Reset(cmd).Args(args...).Err(); err == nil {
+		if cc.Vectored() {
+			// flush buffered data first, then bypass e.bw for big args
+			if err = e.bw.Flush(); err == nil {
+				err = cc.Writev(e.w)
+			}
+		} else {
+			err = cc.Dump(e.bw)
+		}
+	}
+	cc.resetbig()
+	commandPool.Put(cc)
+	return
+}
+
+func (e *Encoder) writeline(t byte, s string) error {
+	e.bw.WriteByte(t)
+	e.bw.WriteString(s)
+	_, err := e.bw.WriteString(CRLF)
+	return err
+}
+
+func (e *Encoder) writeint(t byte, i int64) error {
+	b := append(e.tmp[:0], t)
+	b = strconv.AppendInt(b, i, 10)
+	b = append(b, CR, LF)
+	_, err := e.bw.Write(b)
+	return err
+}
+
+func (e *Encoder) writeblob(t byte, s string) error {
+	e.writeint(t, int64(len(s)))
+	e.bw.WriteString(s)
+	_, err := e.bw.WriteString(CRLF)
+	return err
+}
+
+// WriteSimpleString writes a Simple String, s must not contain CR or LF
+func (e *Encoder) WriteSimpleString(s string) error {
+	return e.writeline('+', s)
+}
+
+// WriteError writes a Simple Error, s must not contain CR or LF
+func (e *Encoder) WriteError(s string) error {
+	return e.writeline('-', s)
+}
+
+// WriteInteger writes an Integer
+func (e *Encoder) WriteInteger(i int64) error {
+	return e.writeint(':', i)
+}
+
+// WriteBulk writes a Bulk String
+func (e *Encoder) WriteBulk(b []byte) error {
+	return e.writeblob('$', ss(b))
+}
+
+// WriteBulkString writes a Bulk String
+func (e *Encoder) WriteBulkString(s string) error {
+	return e.writeblob('$', s)
+}
+
+// WriteNil writes a Null Bulk String
+func (e *Encoder) WriteNil() error {
+	return e.writeline('$', "-1")
+}
+
+// WriteNilArray writes a Null Array
+func (e *Encoder) WriteNilArray() error {
+	return e.writeline('*', "-1")
+}
+
+// WriteArrayHeader writes the header of an Array with n elements,
+// the elements must be written after it.
+func (e *Encoder) WriteArrayHeader(n int) error {
+	return e.writeint('*', int64(n))
+}
+
+// WriteNull writes a RESP3 Null
+func (e *Encoder) WriteNull() error {
+	return e.writeline('_', "")
+}
+
+// WriteDouble writes a RESP3 Double
+func (e *Encoder) WriteDouble(f float64) error {
+	var b []byte
+	switch {
+	case math.IsInf(f, 1):
+		b = append(e.tmp[:0], "inf"...)
+	case math.IsInf(f, -1):
+		b = append(e.tmp[:0], "-inf"...)
+	case math.IsNaN(f):
+		b = append(e.tmp[:0], "nan"...)
+	default:
+		b = strconv.AppendFloat(e.tmp[:0], f, 'g', -1, 64)
+	}
+	return e.writeline(',', ss(b))
+}
+
+// WriteBool writes a RESP3 Boolean
+func (e *Encoder) WriteBool(v bool) error {
+	if v {
+		return e.writeline('#', "t")
+	}
+	return e.writeline('#', "f")
+}
+
+// WriteBigNumber writes a RESP3 Big Number, s must be an integer in decimal
+func (e *Encoder) WriteBigNumber(s string) error {
+	return e.writeline('(', s)
+}
+
+// WriteBlobError writes a RESP3 Blob Error
+func (e *Encoder) WriteBlobError(s string) error {
+	return e.writeblob('!', s)
+}
+
+// WriteVerbatim writes a RESP3 Verbatim String, format must be 3 bytes like "txt" or "mkd"
+func (e *Encoder) WriteVerbatim(format, s string) error {
+	e.writeint('=', int64(len(format)+1+len(s)))
+	e.bw.WriteString(format)
+	e.bw.WriteByte(':')
+	e.bw.WriteString(s)
+	_, err := e.bw.WriteString(CRLF)
+	return err
+}
+
+// WriteMapHeader writes the header of a RESP3 Map with n pairs,
+// the 2*n keys and values must be written after it.
+func (e *Encoder) WriteMapHeader(n int) error {
+	return e.writeint('%', int64(n))
+}
+
+// WriteSetHeader writes the header of a RESP3 Set with n elements
+func (e *Encoder) WriteSetHeader(n int) error {
+	return e.writeint('~', int64(n))
+}
+
+// WritePushHeader writes the header of a RESP3 Push with n elements
+func (e *Encoder) WritePushHeader(n int) error {
+	return e.writeint('>', int64(n))
+}
+
+// WriteAttributeHeader writes the header of a RESP3 Attribute with n pairs,
+// the 2*n keys and values, and then the value it belongs to must be written after it.
+func (e *Encoder) WriteAttributeHeader(n int) error {
+	return e.writeint('|', int64(n))
+}
+
+// WriteReply writes r with its type
+func (e *Encoder) WriteReply(r *Reply) (err error) {
+	switch r.t {
+	case TypeNil:
+		return e.WriteNil()
+	case TypeError:
+		if bytes.ContainsAny(r.err, CRLF) {
+			return e.WriteBlobError(ss(r.err))
+		}
+		return e.WriteError(ss(r.err))
+	case TypeInteger:
+		return e.WriteInteger(r.i)
+	case TypeSimpleString:
+		return e.writeline('+', ss(r.b))
+	case TypeBulkString:
+		return e.WriteBulk(r.b)
+	case TypeNilArray:
+		return e.WriteNilArray()
+	case TypeDouble:
+		return e.writeline(',', ss(r.b))
+	case TypeBool:
+		return e.WriteBool(r.i != 0)
+	case TypeBigNumber:
+		return e.WriteBigNumber(ss(r.b))
+	case TypeVerbatim:
+		return e.writeblob('=', ss(r.b))
+	case TypeArray:
+		err = e.WriteArrayHeader(len(r.array))
+	case TypeMap:
+		err = e.WriteMapHeader(len(r.array) / 2)
+	case TypeSet:
+		err = e.WriteSetHeader(len(r.array))
+	case TypePush:
+		err = e.WritePushHeader(len(r.array))
+	default:
+		return errTypeMismatch
+	}
+	for i := range r.array {
+		if err != nil {
+			break
+		}
+		err = e.WriteReply(&r.array[i])
+	}
+	return
+}
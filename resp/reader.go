@@ -1,4 +1,4 @@
-package redisgo
+package resp
 
 import (
 	"bytes"
@@ -13,6 +13,9 @@ type reader struct {
 	r  int
 	w  int
 	sz int
+	n  int64 // bytes read from rd
+
+	maxline int // max length of a line without CRLF if > 0
 }
 
 func newReader(r io.Reader, sz int) *reader {
@@ -56,6 +59,7 @@ func (r *reader) readmore(n int) error {
 	readn, err := io.ReadAtLeast(r.rd, r.buf(), n)
 	if readn > 0 {
 		r.w += readn
+		r.n += int64(readn)
 	}
 	return err
 }
@@ -86,6 +90,7 @@ func (r *reader) fillmore() error {
 	readn, err := r.rd.Read(r.buf())
 	if readn > 0 {
 		r.w += readn
+		r.n += int64(readn)
 		if err == io.EOF {
 			err = nil
 		}
@@ -93,7 +98,8 @@ func (r *reader) fillmore() error {
 	return err
 }
 
-// Readline reads line ending with CRLF
+// Readline reads line ending with CRLF,
+// *ProtocolError is returned if no CRLF is found within maxline bytes.
 func (r *reader) Readline() (b []byte, err error) {
 	if r.buffered() == 0 {
 		err = r.fillmore()
@@ -103,6 +109,9 @@ func (r *reader) Readline() (b []byte, err error) {
 		p := r.bytes()
 		pos := bytes.IndexByte(p[start:], LF)
 		if pos > 0 && p[start+pos-1] == CR {
+			if r.maxline > 0 && start+pos-1 > r.maxline {
+				return nil, protoerr("line too long", p[:minint(len(p), 32)])
+			}
 			b = p[: start+pos+1 : start+pos+1]
 			r.r += (start + pos + 1)
 			return
@@ -111,6 +120,9 @@ func (r *reader) Readline() (b []byte, err error) {
 			start += pos + 1
 			continue
 		}
+		if r.maxline > 0 && len(p) > r.maxline+2 {
+			return nil, protoerr("line too long", p[:minint(len(p), 32)])
+		}
 		err = r.fillmore()
 	}
 	return
@@ -1,4 +1,4 @@
-package redisgo
+package resp
 
 import (
 	"bytes"
@@ -0,0 +1,403 @@
+package resp
+
+import (
+	"strconv"
+	"sync"
+)
+
+// Type represents the type of a Reply
+type Type int
+
+const (
+	TypeUnset Type = iota
+	TypeNil        // Null Bulk String, or RESP3 Null
+	TypeError      // Simple Error, or RESP3 Blob Error
+	TypeInteger
+	TypeSimpleString
+	TypeBulkString
+	TypeNilArray
+	TypeArray
+
+	// RESP3 only types
+	TypeDouble
+	TypeBool
+	TypeBigNumber
+	TypeVerbatim
+	TypeMap // elements are key, value, key, value, ...
+	TypeSet
+	TypePush
+)
+
+var typeNames = [...]string{
+	TypeUnset:        "unset",
+	TypeNil:          "nil",
+	TypeError:        "error",
+	TypeInteger:      "integer",
+	TypeSimpleString: "simple string",
+	TypeBulkString:   "bulk string",
+	TypeNilArray:     "nil array",
+	TypeArray:        "array",
+	TypeDouble:       "double",
+	TypeBool:         "bool",
+	TypeBigNumber:    "big number",
+	TypeVerbatim:     "verbatim string",
+	TypeMap:          "map",
+	TypeSet:          "set",
+	TypePush:         "push",
+}
+
+func (t Type) String() string {
+	if t >= 0 && int(t) < len(typeNames) {
+		return typeNames[t]
+	}
+	return "unknown"
+}
+
+// isarray returns true if the type contains elements
+func (t Type) isarray() bool {
+	return t == TypeArray || t == TypeMap || t == TypeSet || t == TypePush
+}
+
+// Reply represents a reply of redis
+type Reply struct {
+	p     bool
+	t     Type
+	b     []byte
+	i     int64
+	err   RedisErr
+	array []Reply
+}
+
+var replyPool = sync.Pool{
+	New: func() interface{} {
+		r := new(Reply)
+		r.p = true
+		return r
+	},
+}
+
+// NewReply creates Reply instance from pool
+func NewReply() *Reply {
+	return replyPool.Get().(*Reply)
+}
+
+// Reset resets fields of Reply
+func (r *Reply) Reset() {
+	r.t = TypeUnset
+	r.b = nil // never reuse it
+	r.i = -1
+	for i := range r.array {
+		r.array[i].Reset() // remove ref for gc friendly
+	}
+	r.array = r.array[:0]
+}
+
+// Type returns the type of the reply
+func (r *Reply) Type() Type {
+	return r.t
+}
+
+// IsNil returns true if redis response a "Null Bulk String"
+func (r *Reply) IsNil() bool {
+	return r.t == TypeNil
+}
+
+// IsOK returns true if redis reply "+OK"
+func (r *Reply) IsOK() bool {
+	return r.t == TypeSimpleString && len(r.b) == 2 && r.b[0] == 'O' && r.b[1] == 'K'
+}
+
+// Bytes returns bytes of "Simple Strings" and "Bulk Strings" protocol:
+// https://redis.io/topics/protocol#resp-simple-strings and
+// https://redis.io/topics/protocol#resp-bulk-strings
+// For RESP3, the text of Double, Big Number and Verbatim String (without format) is returned.
+func (r *Reply) Bytes() ([]byte, error) {
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	switch r.t {
+	case TypeSimpleString, TypeBulkString, TypeDouble, TypeBigNumber:
+		return r.b, nil
+	case TypeVerbatim:
+		return r.b[4:], nil // skip "txt:"
+	}
+	return nil, errTypeMismatch
+}
+
+// Integer returns int64 of integer protocol:
+// https://redis.io/topics/protocol#resp-integers
+func (r *Reply) Integer() (int64, error) {
+	if err := r.Err(); err != nil {
+		return 0, err
+	}
+	if r.t != TypeInteger {
+		return 0, errTypeMismatch
+	}
+	return r.i, nil
+}
+
+// Array returns []Reply of array protocol:
+// https://redis.io/topics/protocol#resp-arrays
+// For RESP3, elements of Map, Set and Push are returned, Map is flattened to key, value pairs.
+func (r *Reply) Array() ([]Reply, error) {
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if !r.t.isarray() && r.t != TypeNilArray {
+		return nil, errTypeMismatch
+	}
+	if r.t == TypeNilArray {
+		return nil, nil
+	}
+	return r.array, nil
+}
+
+// Str returns the string of Bytes, or the decimal string of Integer
+func (r *Reply) Str() (string, error) {
+	if r.t == TypeInteger {
+		return strconv.FormatInt(r.i, 10), nil
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// String implements fmt.Stringer and returns the text of r for debugging and logging like:
+// "hello", (integer) 1, (nil), (error) ERR x, ["a" "b"], {"k": "v"}.
+// Use Str for the string value of r.
+func (r *Reply) String() string {
+	return string(r.appendtext(nil))
+}
+
+func (r *Reply) appendtext(b []byte) []byte {
+	switch r.t {
+	case TypeNil, TypeNilArray:
+		return append(b, "(nil)"...)
+	case TypeError:
+		return append(append(b, "(error) "...), r.err...)
+	case TypeInteger:
+		return strconv.AppendInt(append(b, "(integer) "...), r.i, 10)
+	case TypeBool:
+		return strconv.AppendBool(b, r.i == 1)
+	case TypeDouble, TypeBigNumber:
+		return append(append(append(append(b, '('), r.t.String()...), ") "...), r.b...)
+	case TypeSimpleString, TypeBulkString:
+		return strconv.AppendQuote(b, ss(r.b))
+	case TypeVerbatim:
+		return strconv.AppendQuote(b, ss(r.b[4:]))
+	case TypeArray, TypeSet, TypePush:
+		b = append(b, '[')
+		for i := range r.array {
+			if i > 0 {
+				b = append(b, ' ')
+			}
+			b = r.array[i].appendtext(b)
+		}
+		return append(b, ']')
+	case TypeMap:
+		b = append(b, '{')
+		for i := 0; i+1 < len(r.array); i += 2 {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = r.array[i].appendtext(b)
+			b = append(b, ": "...)
+			b = r.array[i+1].appendtext(b)
+		}
+		return append(b, '}')
+	}
+	return append(append(append(b, '('), r.t.String()...), ')')
+}
+
+// Int64 returns Integer, or parses the text of strings like replies of HGET
+func (r *Reply) Int64() (int64, error) {
+	if r.t == TypeInteger {
+		return r.i, nil
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(ss(b), 10, 64)
+}
+
+// Float64 returns float64 of Double, Integer, or parses the text of strings like replies of ZSCORE
+func (r *Reply) Float64() (float64, error) {
+	if r.t == TypeInteger {
+		return float64(r.i), nil
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(ss(b), 64)
+}
+
+// Bool returns true for ":1", "+OK" and RESP3 "#t", false for ":0" and "#f"
+func (r *Reply) Bool() (bool, error) {
+	if err := r.Err(); err != nil {
+		return false, err
+	}
+	switch r.t {
+	case TypeInteger, TypeBool:
+		if r.i == 0 || r.i == 1 {
+			return r.i == 1, nil
+		}
+	case TypeSimpleString:
+		if r.IsOK() {
+			return true, nil
+		}
+	}
+	return false, errTypeMismatch
+}
+
+// Strings returns elements of Array as strings, see String.
+// Nil elements are returned as "", use Array and IsNil for telling them from empty strings.
+func (r *Reply) Strings() ([]string, error) {
+	aa, err := r.Array()
+	if err != nil || aa == nil {
+		return nil, err
+	}
+	ret := make([]string, len(aa))
+	for i := range aa {
+		if aa[i].IsNil() {
+			continue
+		}
+		if ret[i], err = aa[i].Str(); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Int64s returns elements of Array as int64, see Int64.
+// Nil elements are returned as 0.
+func (r *Reply) Int64s() ([]int64, error) {
+	aa, err := r.Array()
+	if err != nil || aa == nil {
+		return nil, err
+	}
+	ret := make([]int64, len(aa))
+	for i := range aa {
+		if aa[i].IsNil() {
+			continue
+		}
+		if ret[i], err = aa[i].Int64(); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Float64s returns elements of Array as float64, see Float64.
+// Nil elements are returned as 0, like missing members of ZMSCORE.
+func (r *Reply) Float64s() ([]float64, error) {
+	aa, err := r.Array()
+	if err != nil || aa == nil {
+		return nil, err
+	}
+	ret := make([]float64, len(aa))
+	for i := range aa {
+		if aa[i].IsNil() {
+			continue
+		}
+		if ret[i], err = aa[i].Float64(); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// StringMap returns key, value pairs of RESP3 Map, or Array with even elements like replies of HGETALL.
+// Nil values are returned as "", and nil keys are not allowed.
+func (r *Reply) StringMap() (map[string]string, error) {
+	aa, err := r.pairs()
+	if err != nil || aa == nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(aa)/2)
+	for i := 0; i < len(aa); i += 2 {
+		k, err := aa[i].Str()
+		if err != nil {
+			return nil, err
+		}
+		v := ""
+		if !aa[i+1].IsNil() {
+			if v, err = aa[i+1].Str(); err != nil {
+				return nil, err
+			}
+		}
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+// BytesMap is the same as StringMap except that nil values are returned as nil.
+// Values are not copied, like Bytes.
+func (r *Reply) BytesMap() (map[string][]byte, error) {
+	aa, err := r.pairs()
+	if err != nil || aa == nil {
+		return nil, err
+	}
+	ret := make(map[string][]byte, len(aa)/2)
+	for i := 0; i < len(aa); i += 2 {
+		k, err := aa[i].Bytes()
+		if err != nil {
+			return nil, err
+		}
+		var v []byte
+		if !aa[i+1].IsNil() {
+			if v, err = aa[i+1].Bytes(); err != nil {
+				return nil, err
+			}
+		}
+		ret[string(k)] = v
+	}
+	return ret, nil
+}
+
+// pairs returns elements of Map or Array, the number of elements must be even
+func (r *Reply) pairs() ([]Reply, error) {
+	aa, err := r.Array()
+	if err != nil {
+		return nil, err
+	}
+	if len(aa)%2 != 0 {
+		return nil, errTypeMismatch
+	}
+	return aa, nil
+}
+
+// next appends an element to r.array and returns it, the element is reused if possible
+func (r *Reply) next() *Reply {
+	if n := len(r.array); n < cap(r.array) {
+		r.array = r.array[:n+1]
+	} else {
+		r.array = append(r.array, Reply{})
+	}
+	return &r.array[len(r.array)-1]
+}
+
+// Err returns ErrNil or RedisErr or nil
+func (r *Reply) Err() error {
+	if r.t == TypeError {
+		return r.err
+	}
+	if r.t == TypeNil {
+		return ErrNil
+	}
+	return nil
+}
+
+// Free resets Reply and put it back to memory pool
+func (r *Reply) Free() {
+	if r == nil {
+		return
+	}
+	r.Reset()
+	if r.p {
+		replyPool.Put(r)
+	}
+}
@@ -0,0 +1,112 @@
+package resp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decode returns the Reply of RESP text s
+func decode(t *testing.T, s string) *Reply {
+	t.Helper()
+	r := NewReply()
+	if err := NewDecoder(strings.NewReader(s)).Decode(r); err != nil {
+		t.Fatal(s, err)
+	}
+	return r
+}
+
+func TestReplyAccessors(t *testing.T) {
+	if v, err := decode(t, ",1.5\r\n").Float64(); err != nil || v != 1.5 {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, "$4\r\n-2.5\r\n").Float64(); err != nil || v != -2.5 {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, ":3\r\n").Float64(); err != nil || v != 3 {
+		t.Fatal(v, err)
+	}
+	if _, err := decode(t, "$1\r\nx\r\n").Float64(); err == nil {
+		t.Fatal("expect err")
+	}
+	if v, err := decode(t, "+hello\r\n").Str(); err != nil || v != "hello" {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, ":-7\r\n").Str(); err != nil || v != "-7" {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, "$2\r\n42\r\n").Int64(); err != nil || v != 42 {
+		t.Fatal(v, err)
+	}
+	if _, err := decode(t, "$-1\r\n").Str(); err != ErrNil {
+		t.Fatal(err)
+	}
+	if _, err := decode(t, "-ERR x\r\n").Float64(); err == nil || err.Error() != "ERR x" {
+		t.Fatal(err)
+	}
+
+	for s, expect := range map[string]bool{":1\r\n": true, ":0\r\n": false, "+OK\r\n": true, "#t\r\n": true, "#f\r\n": false} {
+		if v, err := decode(t, s).Bool(); err != nil || v != expect {
+			t.Fatal(s, v, err)
+		}
+	}
+	for _, s := range []string{":2\r\n", "+QUEUED\r\n", "$1\r\n1\r\n"} {
+		if _, err := decode(t, s).Bool(); err == nil {
+			t.Fatal(s, "expect err")
+		}
+	}
+
+	if v, err := decode(t, "*3\r\n$1\r\na\r\n$-1\r\n:1\r\n").Strings(); err != nil || !reflect.DeepEqual(v, []string{"a", "", "1"}) {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, "*3\r\n:1\r\n$2\r\n-2\r\n_\r\n").Int64s(); err != nil || !reflect.DeepEqual(v, []int64{1, -2, 0}) {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, "*3\r\n$3\r\n1.5\r\n,2\r\n$-1\r\n").Float64s(); err != nil || !reflect.DeepEqual(v, []float64{1.5, 2, 0}) {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, "*-1\r\n").Strings(); err != nil || v != nil {
+		t.Fatal(v, err)
+	}
+	if _, err := decode(t, "*2\r\n:1\r\n*0\r\n").Int64s(); err == nil {
+		t.Fatal("expect err")
+	}
+
+	if v, err := decode(t, "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$-1\r\n").StringMap(); err != nil ||
+		!reflect.DeepEqual(v, map[string]string{"a": "1", "b": ""}) {
+		t.Fatal(v, err)
+	}
+	if v, err := decode(t, "%2\r\n+a\r\n+1\r\n+b\r\n_\r\n").BytesMap(); err != nil ||
+		!reflect.DeepEqual(v, map[string][]byte{"a": []byte("1"), "b": nil}) {
+		t.Fatal(v, err)
+	}
+	if _, err := decode(t, "*3\r\n+a\r\n+b\r\n+c\r\n").StringMap(); err == nil {
+		t.Fatal("expect err")
+	}
+	if _, err := decode(t, "*2\r\n$-1\r\n+b\r\n").StringMap(); err == nil {
+		t.Fatal("expect err")
+	}
+}
+
+func TestReplyString(t *testing.T) {
+	for s, expect := range map[string]string{
+		"+OK\r\n":                        `"OK"`,
+		"$2\r\na\"\r\n":                  `"a\""`,
+		":-1\r\n":                        "(integer) -1",
+		"$-1\r\n":                        "(nil)",
+		"*-1\r\n":                        "(nil)",
+		"-ERR x\r\n":                     "(error) ERR x",
+		",1.5\r\n":                       "(double) 1.5",
+		"#t\r\n":                         "true",
+		"=7\r\ntxt:abc\r\n":              `"abc"`,
+		"*3\r\n+a\r\n:1\r\n*1\r\n_\r\n":  `["a" (integer) 1 [(nil)]]`,
+		"%2\r\n+a\r\n:1\r\n+b\r\n*0\r\n": `{"a": (integer) 1, "b": []}`,
+	} {
+		r := decode(t, s)
+		if v := r.String(); v != expect {
+			t.Fatalf("%q: expect %s, get %s", s, expect, v)
+		}
+		var _ fmt.Stringer = r
+	}
+}
@@ -0,0 +1,64 @@
+// Package resp implements the REdis Serialization Protocol (RESP2 and RESP3):
+// https://redis.io/docs/reference/protocol-spec/
+//
+// Encoder writes RESP values to any io.Writer and Decoder reads Reply from any io.Reader,
+// they are the same code used by github.com/xiaost/redisgo,
+// and can be used for parsing captured traffic, AOF files or test fixtures.
+package resp
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+const (
+	CR = '\r'
+	LF = '\n'
+
+	CRLF = "\r\n"
+)
+
+// the texts are kept from package redisgo which the errors are moved from
+var (
+	ErrNil            = errors.New("redisgo: nil")
+	ErrProtocol       = errors.New("redisgo: protocol err")
+	ErrInvalidArgType = errors.New("redisgo: invalid args type")
+
+	errTypeMismatch = errors.New("redisgo: type mismatch")
+)
+
+// ProtocolError represents an invalid or unacceptable RESP value
+type ProtocolError struct {
+	Reason string
+	Header string // the offending line without CRLF
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s: %q", ErrProtocol, e.Reason, e.Header)
+}
+
+// Unwrap returns ErrProtocol for errors.Is
+func (e *ProtocolError) Unwrap() error { return ErrProtocol }
+
+func protoerr(reason string, header []byte) error {
+	return &ProtocolError{Reason: reason, Header: string(header)}
+}
+
+// RedisErr represents a server side err
+// https://redis.io/topics/protocol#resp-errors
+type RedisErr []byte
+
+// RedisErr implements error interface
+func (err RedisErr) Error() string { return ss(err) }
+
+func ss(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+func minint(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
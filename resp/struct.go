@@ -0,0 +1,197 @@
+package resp
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Args is a list of command args, it's used for building args of a command like
+//
+//	conn.Do("HSET", resp.Args{"user:1"}.FromStruct(&u)...)
+type Args []interface{}
+
+// Add returns the result of appending v to args
+func (args Args) Add(v ...interface{}) Args {
+	return append(args, v...)
+}
+
+// FromStruct returns the result of appending field, value pairs of struct v to args, like for HSET.
+// v must be a struct or a pointer to struct, see ScanStruct for naming fields.
+//
+// Fields with the option `redis:",omitempty"` are skipped if they are zero values,
+// and nil pointers are always skipped.
+// Values are encoded as:
+//
+//	bool: 1 or 0
+//	time.Duration: integer nanoseconds
+//	encoding.TextMarshaler: the text of it, like RFC 3339 for time.Time
+//	numbers, string and []byte: as they are
+//
+// TextMarshaler and other types are encoded when the command is written, see Encoder.WriteCommand.
+func (args Args) FromStruct(v interface{}) Args {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("resp: FromStruct(non-struct %T)", v))
+	}
+	for _, f := range cachedstruct(rv.Type()).fields {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitempty && fv.IsZero() {
+			continue
+		}
+		if a, ok := structarg(fv); ok {
+			args = append(args, f.name, a)
+		}
+	}
+	return args
+}
+
+// structarg returns the arg of field value v, or false if v is a nil pointer.
+// Named types of basic kinds are converted for the encoder, others are encoded by the encoder.
+func structarg(v reflect.Value) (interface{}, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()), true
+	}
+	if v.Type().Implements(textMarshalerType) {
+		return v.Interface(), true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textMarshalerType) {
+		return v.Addr().Interface(), true // MarshalText with pointer receiver
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return v.String(), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), true
+		}
+	}
+	return v.Interface(), true
+}
+
+// ScanStruct fills struct dst from an array reply of field, value pairs like HGETALL,
+// or from values of fields in order like HMGET dst fields...
+//
+// Fields are named by the tag `redis:"name"` or the field name, `redis:"-"` is ignored,
+// and fields of embedded structs are promoted.
+// Values are decoded like Unmarshal, time.Duration accepts its string or integer nanoseconds,
+// and encoding.TextUnmarshaler including time.Time is decoded from the text.
+// Nil values set fields to zero values, and unknown fields of pairs are ignored.
+func (r *Reply) ScanStruct(dst interface{}, fields ...string) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("resp: ScanStruct(non-pointer-to-struct %T)", dst)
+	}
+	if len(fields) == 0 {
+		if r.t == TypeError {
+			return r.err
+		}
+		return r.unmarshal(rv.Elem(), "")
+	}
+	aa, err := r.Array()
+	if err != nil {
+		return err
+	}
+	if len(aa) != len(fields) {
+		return fmt.Errorf("resp: ScanStruct %d fields with %d elements", len(fields), len(aa))
+	}
+	sv := rv.Elem()
+	info := cachedstruct(sv.Type())
+	for i, name := range fields {
+		f := info.byname[name]
+		if f == nil {
+			return fmt.Errorf("resp: ScanStruct unknown field %q of %s", name, sv.Type())
+		}
+		if err := aa[i].unmarshal(sv.FieldByIndex(f.index), "["+strconv.Itoa(i)+"]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type fieldinfo struct {
+	name      string
+	index     []int
+	omitempty bool
+}
+
+type structinfo struct {
+	fields []*fieldinfo
+	byname map[string]*fieldinfo
+}
+
+var structcache sync.Map // reflect.Type -> *structinfo
+
+func cachedstruct(t reflect.Type) *structinfo {
+	if v, ok := structcache.Load(t); ok {
+		return v.(*structinfo)
+	}
+	info := &structinfo{byname: make(map[string]*fieldinfo)}
+	info.add(t, nil)
+	v, _ := structcache.LoadOrStore(t, info)
+	return v.(*structinfo)
+}
+
+// add adds fields of t, index is the index of t in the outermost struct.
+// Fields of embedded structs are added after fields of t, so that they are shadowed by the latter.
+func (info *structinfo) add(t reflect.Type, index []int) {
+	var embedded []int
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("redis")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" && sf.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, i) // exported fields of it are promoted even if it's unexported
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if _, ok := info.byname[name]; ok {
+			continue
+		}
+		fi := &fieldinfo{
+			name:      name,
+			index:     append(append([]int(nil), index...), i),
+			omitempty: strings.Contains(","+opts+",", ",omitempty,"),
+		}
+		info.fields = append(info.fields, fi)
+		info.byname[name] = fi
+	}
+	for _, i := range embedded {
+		info.add(t.Field(i).Type, append(append([]int(nil), index...), i))
+	}
+}
@@ -0,0 +1,140 @@
+package resp
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte(strings.Repeat("*", int(l))), nil
+}
+
+func (l *level) UnmarshalText(b []byte) error {
+	*l = level(len(b))
+	return nil
+}
+
+type base struct {
+	ID   int64  `redis:"id"`
+	Name string `redis:"name"` // shadowed by user.Name
+}
+
+type user struct {
+	base
+	Name     string        `redis:"name"`
+	Admin    bool          `redis:"admin"`
+	Score    float64       `redis:"score,omitempty"`
+	TTL      time.Duration `redis:"ttl"`
+	Created  time.Time     `redis:"created"`
+	Nick     *string       `redis:"nick"`
+	Level    level         `redis:"level"`
+	Data     []byte        `redis:"data,omitempty"`
+	Ignored  string        `redis:"-"`
+	internal int
+}
+
+// reply encodes args as an array of bulk strings
+func reply(t *testing.T, args Args) *Reply {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	e := NewEncoder(buf)
+	e.WriteArrayHeader(len(args))
+	for _, a := range args {
+		var s string
+		switch v := a.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		default:
+			c := commandPool.Get().(*command)
+			if err := c.Reset("X").Args(a).Err(); err != nil {
+				t.Fatal(err)
+			}
+			s = string(c.buf[bytes.LastIndex(c.buf[:len(c.buf)-2], []byte(CRLF))+2 : len(c.buf)-2])
+		}
+		e.WriteBulkString(s)
+	}
+	e.Flush()
+	return decode(t, buf.String())
+}
+
+func TestFromStruct(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	nick := "n"
+	u := user{
+		base:    base{ID: 7, Name: "shadowed"},
+		Name:    "x",
+		Admin:   true,
+		TTL:     1500 * time.Millisecond,
+		Created: created,
+		Nick:    &nick,
+		Level:   3,
+		Ignored: "y",
+	}
+	args := Args{"user:1"}.FromStruct(&u)
+	expect := Args{"user:1", "name", "x", "admin", true, "ttl", 1500 * time.Millisecond,
+		"created", created, "nick", "n", "level", level(3), "id", int64(7)}
+	if !reflect.DeepEqual(args, expect) {
+		t.Fatalf("expect %v, get %v", expect, args)
+	}
+
+	var got user
+	if err := reply(t, args[1:]).ScanStruct(&got); err != nil {
+		t.Fatal(err)
+	}
+	u.base.Name = ""
+	u.Ignored = ""
+	if !reflect.DeepEqual(got, u) {
+		t.Fatalf("expect %+v, get %+v", u, got)
+	}
+
+	// omitempty and nil pointers
+	args = Args{}.FromStruct(user{Score: 1.5, Data: []byte("d")})
+	for i := 0; i < len(args); i += 2 {
+		if args[i] == "nick" {
+			t.Fatal(args)
+		}
+	}
+	if args[len(args)-4] != "data" || args[4] != "score" {
+		t.Fatal(args)
+	}
+}
+
+func TestScanStruct(t *testing.T) {
+	// HMGET with fields, nil values reset fields
+	var u user
+	u.Name = "old"
+	r := decode(t, "*4\r\n$-1\r\n$1\r\n1\r\n:1000\r\n$2\r\n10\r\n")
+	if err := r.ScanStruct(&u, "name", "admin", "ttl", "id"); err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "" || !u.Admin || u.TTL != 1000 || u.ID != 10 {
+		t.Fatalf("%+v", u)
+	}
+	if err := r.ScanStruct(&u, "name"); err == nil {
+		t.Fatal("expect err")
+	}
+	if err := r.ScanStruct(&u, "name", "admin", "ttl", "unknown"); err == nil {
+		t.Fatal("expect err")
+	}
+	if err := r.ScanStruct(u); err == nil {
+		t.Fatal("expect err")
+	}
+
+	err := decode(t, "*4\r\n+name\r\n+x\r\n+created\r\n+yesterday\r\n").ScanStruct(&u)
+	if err == nil || !strings.Contains(err.Error(), "element [3] of simple string into time.Time") {
+		t.Fatal(err)
+	}
+	if err := decode(t, "*0\r\n").ScanStruct(&u); err != nil {
+		t.Fatal(err)
+	}
+	if err := decode(t, "-ERR x\r\n").ScanStruct(&u); err == nil || err.Error() != "ERR x" {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,318 @@
+package resp
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DecodeError represents a failure of converting a reply or an element of it to a Go value
+type DecodeError struct {
+	Path   string // indexes of the element, like "[1][0]", empty for the reply itself
+	Type   Type   // the RESP type found
+	GoType reflect.Type
+	Err    error // the cause, may be nil
+}
+
+func (e *DecodeError) Error() string {
+	s := "resp: cannot decode "
+	if e.Path != "" {
+		s += "element " + e.Path + " of "
+	}
+	s += e.Type.String() + " into " + e.GoType.String()
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Unwrap returns the cause for errors.Is and errors.As
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// Unmarshal decodes the reply into v, which must be a non-nil pointer.
+//
+// Strings, integers, doubles and booleans are converted to the kind of v like the accessors,
+// arrays are decoded into slices, RESP3 maps and arrays of key, value pairs into maps and structs,
+// and any reply into interface{}, where strings are string, arrays are []interface{} and maps are map[string]interface{}.
+// Structs are decoded like ScanStruct.
+//
+// Nil elements set the value to its zero value, while a nil reply returns ErrNil except for
+// pointers, slices, maps and interfaces. Errors replied by redis are returned as RedisErr.
+func (r *Reply) Unmarshal(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("resp: Unmarshal(non-pointer %T)", v)
+	}
+	if r.t == TypeError {
+		return r.err
+	}
+	if r.t == TypeNil {
+		switch rv.Elem().Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		default:
+			return ErrNil
+		}
+	}
+	return r.unmarshal(rv.Elem(), "")
+}
+
+// Scan fills dst positionally from elements of an array reply, like HMGET or MGET.
+// Each dst must be a pointer, see Unmarshal, or nil for skipping the element.
+// It fails if there are fewer elements than dst, extra elements are ignored.
+func (r *Reply) Scan(dst ...interface{}) error {
+	aa, err := r.Array()
+	if err != nil {
+		return err
+	}
+	if len(aa) < len(dst) {
+		return fmt.Errorf("resp: Scan %d destinations with %d elements", len(dst), len(aa))
+	}
+	for i, v := range dst {
+		if v == nil {
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return fmt.Errorf("resp: Scan(non-pointer %T) at %d", v, i)
+		}
+		if err := aa[i].unmarshal(rv.Elem(), "["+strconv.Itoa(i)+"]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reply) decodeerr(v reflect.Value, path string, err error) error {
+	if err == errTypeMismatch {
+		err = nil // implied by the message
+	}
+	return &DecodeError{Path: path, Type: r.t, GoType: v.Type(), Err: err}
+}
+
+func (r *Reply) unmarshal(v reflect.Value, path string) error {
+	switch r.t {
+	case TypeError:
+		return r.decodeerr(v, path, r.err)
+	case TypeNil, TypeNilArray:
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return r.unmarshal(v.Elem(), path)
+	}
+	if v.Type() == durationType {
+		return r.unmarshalduration(v, path)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		s, err := r.Str()
+		if err == nil {
+			err = v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		}
+		if err != nil {
+			return r.decodeerr(v, path, err)
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			break
+		}
+		x, err := r.value(path)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(x))
+		return nil
+	case reflect.String:
+		s, err := r.Str()
+		if err != nil {
+			return r.decodeerr(v, path, err)
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		b, err := r.Bool()
+		if err != nil && r.t == TypeBulkString {
+			b, err = strconv.ParseBool(ss(r.b))
+		}
+		if err != nil {
+			return r.decodeerr(v, path, err)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := r.Int64()
+		if err == nil && v.OverflowInt(i) {
+			err = strconv.ErrRange
+		}
+		if err != nil {
+			return r.decodeerr(v, path, err)
+		}
+		v.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		var err error
+		if r.t == TypeInteger {
+			if u = uint64(r.i); r.i < 0 {
+				err = strconv.ErrRange
+			}
+		} else if b, e := r.Bytes(); e != nil {
+			err = e
+		} else {
+			u, err = strconv.ParseUint(ss(b), 10, 64)
+		}
+		if err == nil && v.OverflowUint(u) {
+			err = strconv.ErrRange
+		}
+		if err != nil {
+			return r.decodeerr(v, path, err)
+		}
+		v.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := r.Float64()
+		if err == nil && v.OverflowFloat(f) {
+			err = strconv.ErrRange
+		}
+		if err != nil {
+			return r.decodeerr(v, path, err)
+		}
+		v.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && !r.t.isarray() {
+			b, err := r.Bytes()
+			if err != nil {
+				return r.decodeerr(v, path, err)
+			}
+			v.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		if !r.t.isarray() {
+			break
+		}
+		s := reflect.MakeSlice(v.Type(), len(r.array), len(r.array))
+		for i := range r.array {
+			if err := r.array[i].unmarshal(s.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case reflect.Map:
+		if !r.t.isarray() || len(r.array)%2 != 0 {
+			break
+		}
+		t := v.Type()
+		m := reflect.MakeMapWithSize(t, len(r.array)/2)
+		for i := 0; i < len(r.array); i += 2 {
+			k := reflect.New(t.Key()).Elem()
+			if err := r.array[i].unmarshal(k, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+			e := reflect.New(t.Elem()).Elem()
+			if err := r.array[i+1].unmarshal(e, path+"["+strconv.Itoa(i+1)+"]"); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		if !r.t.isarray() || len(r.array)%2 != 0 {
+			break
+		}
+		info := cachedstruct(v.Type())
+		for i := 0; i < len(r.array); i += 2 {
+			name, err := r.array[i].Bytes()
+			if err != nil {
+				return r.array[i].decodeerr(reflect.ValueOf(""), path+"["+strconv.Itoa(i)+"]", err)
+			}
+			f := info.byname[ss(name)]
+			if f == nil {
+				continue
+			}
+			if err := r.array[i+1].unmarshal(v.FieldByIndex(f.index), path+"["+strconv.Itoa(i+1)+"]"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return r.decodeerr(v, path, errTypeMismatch)
+}
+
+// unmarshalduration decodes the string of time.Duration, or integer nanoseconds
+func (r *Reply) unmarshalduration(v reflect.Value, path string) error {
+	if r.t == TypeInteger {
+		v.SetInt(r.i)
+		return nil
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		return r.decodeerr(v, path, err)
+	}
+	d, err := time.ParseDuration(ss(b))
+	if err != nil {
+		n, e := strconv.ParseInt(ss(b), 10, 64)
+		if e != nil {
+			return r.decodeerr(v, path, err)
+		}
+		d = time.Duration(n)
+	}
+	v.SetInt(int64(d))
+	return nil
+}
+
+// value returns the natural Go value of the reply for interface{}
+func (r *Reply) value(path string) (interface{}, error) {
+	switch r.t {
+	case TypeError:
+		return nil, &DecodeError{Path: path, Type: r.t, GoType: reflect.TypeOf((*interface{})(nil)).Elem(), Err: r.err}
+	case TypeNil, TypeNilArray:
+		return nil, nil
+	case TypeInteger:
+		return r.i, nil
+	case TypeBool:
+		return r.i == 1, nil
+	case TypeDouble:
+		return strconv.ParseFloat(ss(r.b), 64)
+	case TypeMap:
+		m := make(map[string]interface{}, len(r.array)/2)
+		for i := 0; i < len(r.array); i += 2 {
+			k, err := r.array[i].value(path + "[" + strconv.Itoa(i) + "]")
+			if err != nil {
+				return nil, err
+			}
+			e, err := r.array[i+1].value(path + "[" + strconv.Itoa(i+1) + "]")
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = e
+		}
+		return m, nil
+	}
+	if r.t.isarray() {
+		a := make([]interface{}, len(r.array))
+		for i := range r.array {
+			e, err := r.array[i].value(path + "[" + strconv.Itoa(i) + "]")
+			if err != nil {
+				return nil, err
+			}
+			a[i] = e
+		}
+		return a, nil
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,124 @@
+package resp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	var s string
+	if err := decode(t, "$2\r\nab\r\n").Unmarshal(&s); err != nil || s != "ab" {
+		t.Fatal(s, err)
+	}
+	var i8 int8
+	if err := decode(t, ":300\r\n").Unmarshal(&i8); err == nil {
+		t.Fatal("expect overflow")
+	}
+	var u uint
+	if err := decode(t, ":-1\r\n").Unmarshal(&u); err == nil {
+		t.Fatal("expect err")
+	}
+	var f float32
+	if err := decode(t, "$3\r\n2.5\r\n").Unmarshal(&f); err != nil || f != 2.5 {
+		t.Fatal(f, err)
+	}
+	var b bool
+	if err := decode(t, "$4\r\ntrue\r\n").Unmarshal(&b); err != nil || !b {
+		t.Fatal(b, err)
+	}
+	var bs []byte
+	if err := decode(t, "+x\r\n").Unmarshal(&bs); err != nil || string(bs) != "x" {
+		t.Fatal(bs, err)
+	}
+
+	// nil
+	if err := decode(t, "$-1\r\n").Unmarshal(&s); err != ErrNil {
+		t.Fatal(err)
+	}
+	p := &s
+	if err := decode(t, "$-1\r\n").Unmarshal(&p); err != nil || p != nil {
+		t.Fatal(p, err)
+	}
+	var ss []string
+	if err := decode(t, "*-1\r\n").Unmarshal(&ss); err != nil || ss != nil {
+		t.Fatal(ss, err)
+	}
+	if err := decode(t, "-ERR x\r\n").Unmarshal(&s); err == nil || err.Error() != "ERR x" {
+		t.Fatal(err)
+	}
+
+	// aggregates
+	var ps []*string
+	if err := decode(t, "*2\r\n+a\r\n$-1\r\n").Unmarshal(&ps); err != nil || len(ps) != 2 || *ps[0] != "a" || ps[1] != nil {
+		t.Fatal(ps, err)
+	}
+	var m map[string]int
+	if err := decode(t, "%2\r\n+a\r\n:1\r\n+b\r\n$1\r\n2\r\n").Unmarshal(&m); err != nil ||
+		!reflect.DeepEqual(m, map[string]int{"a": 1, "b": 2}) {
+		t.Fatal(m, err)
+	}
+	var nested [][]int64
+	if err := decode(t, "*2\r\n*1\r\n:1\r\n*0\r\n").Unmarshal(&nested); err != nil ||
+		!reflect.DeepEqual(nested, [][]int64{{1}, {}}) {
+		t.Fatal(nested, err)
+	}
+	var st struct {
+		Name  string
+		Age   int    `redis:"age"`
+		Skip  string `redis:"-"`
+		inner int
+	}
+	if err := decode(t, "*8\r\n+Name\r\n+x\r\n+age\r\n:3\r\n+Skip\r\n+y\r\n+other\r\n+z\r\n").Unmarshal(&st); err != nil ||
+		st.Name != "x" || st.Age != 3 || st.Skip != "" {
+		t.Fatal(st, err)
+	}
+	var x interface{}
+	if err := decode(t, "*4\r\n:1\r\n+a\r\n_\r\n%1\r\n+k\r\n,1.5\r\n").Unmarshal(&x); err != nil ||
+		!reflect.DeepEqual(x, []interface{}{int64(1), "a", nil, map[string]interface{}{"k": 1.5}}) {
+		t.Fatal(x, err)
+	}
+
+	// errors name the element and the type
+	err := decode(t, "*2\r\n*1\r\n:1\r\n*1\r\n+x\r\n").Unmarshal(&nested)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Path != "[1][0]" || de.Type != TypeSimpleString {
+		t.Fatal(err)
+	}
+	if !strings.Contains(err.Error(), "element [1][0] of simple string into int64") {
+		t.Fatal(err)
+	}
+	err = decode(t, "*1\r\n-ERR x\r\n").Unmarshal(&ss)
+	var re RedisErr
+	if !errors.As(err, &re) || string(re) != "ERR x" {
+		t.Fatal(err)
+	}
+	if err := decode(t, ":1\r\n").Unmarshal(s); err == nil {
+		t.Fatal("expect err")
+	}
+}
+
+func TestReplyScan(t *testing.T) {
+	var a string
+	var b int
+	var c *float64
+	r := decode(t, "*4\r\n+a\r\n:2\r\n$-1\r\n+d\r\n")
+	if err := r.Scan(&a, &b, &c); err != nil || a != "a" || b != 2 || c != nil {
+		t.Fatal(a, b, c, err)
+	}
+	if err := r.Scan(nil, nil, nil, &a); err != nil || a != "d" {
+		t.Fatal(a, err)
+	}
+	if err := r.Scan(&a, &b, &c, &a, &a); err == nil {
+		t.Fatal("expect err")
+	}
+	err := r.Scan(&b)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Path != "[0]" || err.Error() != "resp: cannot decode element [0] of simple string into int: strconv.ParseInt: parsing \"a\": invalid syntax" {
+		t.Fatal(err)
+	}
+	if err := decode(t, "+OK\r\n").Scan(&a); err == nil {
+		t.Fatal("expect err")
+	}
+}
@@ -0,0 +1,162 @@
+package redisgo
+
+import (
+	"context"
+	"iter"
+)
+
+// ScanOpts represents options of scanners
+type ScanOpts struct {
+	Match string // MATCH pattern if not empty
+	Count int64  // COUNT hint of elements per batch if > 0
+	Type  string // TYPE of keys if not empty, for SCAN only
+
+	// Dedup skips elements returned before, since an element may be returned more than once.
+	// The memory grows with the number of distinct elements.
+	Dedup bool
+}
+
+// Scanner iterates elements of SCAN, HSCAN, SSCAN or ZSCAN, and manages the cursor.
+// A conn of the Pool is borrowed for each batch, instead of the whole scan.
+//
+//	s := redisgo.NewScanner(pool, redisgo.ScanOpts{Match: "user:*"})
+//	for s.Next(ctx) {
+//		key := s.Val()
+//	}
+//	err := s.Err()
+type Scanner struct {
+	p     *Pool
+	cmd   string
+	key   string // empty for SCAN
+	pairs bool   // HSCAN and ZSCAN reply field, value pairs
+	opts  ScanOpts
+
+	cursor  string
+	batch   []string
+	i       int
+	val     string
+	value   string
+	err     error
+	started bool
+	seen    map[string]struct{}
+}
+
+// NewScanner returns Scanner of keys by SCAN
+func NewScanner(p *Pool, opts ScanOpts) *Scanner {
+	return newScanner(p, "SCAN", "", false, opts)
+}
+
+// NewHScanner returns Scanner of fields and values of the hash key by HSCAN
+func NewHScanner(p *Pool, key string, opts ScanOpts) *Scanner {
+	return newScanner(p, "HSCAN", key, true, opts)
+}
+
+// NewSScanner returns Scanner of members of the set key by SSCAN
+func NewSScanner(p *Pool, key string, opts ScanOpts) *Scanner {
+	return newScanner(p, "SSCAN", key, false, opts)
+}
+
+// NewZScanner returns Scanner of members and scores of the sorted set key by ZSCAN
+func NewZScanner(p *Pool, key string, opts ScanOpts) *Scanner {
+	return newScanner(p, "ZSCAN", key, true, opts)
+}
+
+func newScanner(p *Pool, cmd, key string, pairs bool, opts ScanOpts) *Scanner {
+	s := &Scanner{p: p, cmd: cmd, key: key, pairs: pairs, opts: opts, cursor: "0"}
+	if opts.Dedup {
+		s.seen = make(map[string]struct{})
+	}
+	return s
+}
+
+// Next advances to the next element, and returns false after the last element or any err.
+// ctx is passed to Pool.Get of each batch
+func (s *Scanner) Next(ctx context.Context) bool {
+	for {
+		for s.i < len(s.batch) {
+			s.val = s.batch[s.i]
+			s.value = ""
+			if s.pairs && s.i+1 < len(s.batch) {
+				s.value = s.batch[s.i+1]
+				s.i += 2
+			} else {
+				s.i++
+			}
+			if s.seen != nil {
+				if _, ok := s.seen[s.val]; ok {
+					continue
+				}
+				s.seen[s.val] = struct{}{}
+			}
+			return true
+		}
+		if s.err != nil || (s.started && s.cursor == "0") {
+			return false
+		}
+		s.fetch(ctx)
+	}
+}
+
+// fetch gets the next batch and cursor
+func (s *Scanner) fetch(ctx context.Context) {
+	s.started = true
+	s.batch, s.i = s.batch[:0], 0
+	conn, err := s.p.Get(ctx)
+	if err != nil {
+		s.err = err
+		return
+	}
+	defer conn.Close()
+	args := make([]interface{}, 0, 8)
+	if s.key != "" {
+		args = append(args, s.key)
+	}
+	args = append(args, s.cursor)
+	if s.opts.Match != "" {
+		args = append(args, "MATCH", s.opts.Match)
+	}
+	if s.opts.Count > 0 {
+		args = append(args, "COUNT", s.opts.Count)
+	}
+	if s.opts.Type != "" {
+		args = append(args, "TYPE", s.opts.Type)
+	}
+	reply, err := conn.Do(s.cmd, args...)
+	if err != nil {
+		s.err = err
+		return
+	}
+	defer reply.Free()
+	var cursor string
+	s.err = reply.Scan(&cursor, &s.batch)
+	s.cursor = cursor
+}
+
+// Val returns the key of SCAN, the field of HSCAN, or the member of SSCAN and ZSCAN
+func (s *Scanner) Val() string {
+	return s.val
+}
+
+// Value returns the value of HSCAN or the score of ZSCAN, it's empty for SCAN and SSCAN
+func (s *Scanner) Value() string {
+	return s.value
+}
+
+// Err returns the err which stops the scanner
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// All returns an iterator of Val and Value, Err should be checked after the loop.
+//
+//	for field, value := range redisgo.NewHScanner(pool, "h", redisgo.ScanOpts{}).All(ctx) {
+//	}
+func (s *Scanner) All(ctx context.Context) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for s.Next(ctx) {
+			if !yield(s.val, s.value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package redisgo_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+func TestScanner(t *testing.T) {
+	s := redistest.NewServer()
+	defer s.Close()
+	pool := redisgo.NewPool(s.Dial)
+	ctx := context.Background()
+
+	conn, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		conn.DoNoReply("SET", fmt.Sprintf("k%d", i), i)
+		conn.DoNoReply("HSET", "h", fmt.Sprintf("f%d", i), i)
+		conn.DoNoReply("SADD", "s", fmt.Sprintf("m%d", i))
+		conn.DoNoReply("ZADD", "z", i, fmt.Sprintf("m%d", i))
+	}
+	conn.Close()
+
+	var keys []string
+	sc := redisgo.NewScanner(pool, redisgo.ScanOpts{Match: "k*", Count: 7, Type: "string", Dedup: true})
+	for sc.Next(ctx) {
+		keys = append(keys, sc.Val())
+		if pool.Active() != 1 || pool.Idle() != 1 {
+			t.Fatal("conn not returned after batch")
+		}
+	}
+	if err := sc.Err(); err != nil || len(keys) != 25 {
+		t.Fatal(len(keys), err)
+	}
+	sort.Strings(keys)
+	if keys[0] != "k0" || keys[24] != "k9" {
+		t.Fatal(keys)
+	}
+
+	n := 0
+	for field, value := range redisgo.NewHScanner(pool, "h", redisgo.ScanOpts{Count: 10}).All(ctx) {
+		if field[1:] != value {
+			t.Fatal(field, value)
+		}
+		n++
+	}
+	if n != 25 {
+		t.Fatal(n)
+	}
+
+	n = 0
+	for member, score := range redisgo.NewZScanner(pool, "z", redisgo.ScanOpts{Match: "m1*"}).All(ctx) {
+		if member[1:] != score {
+			t.Fatal(member, score)
+		}
+		n++
+	}
+	if n != 11 {
+		t.Fatal(n)
+	}
+
+	sc = redisgo.NewSScanner(pool, "s", redisgo.ScanOpts{})
+	for member, value := range sc.All(ctx) {
+		if value != "" {
+			t.Fatal(member, value)
+		}
+		break
+	}
+
+	// TYPE is for SCAN only
+	sc = redisgo.NewSScanner(pool, "s", redisgo.ScanOpts{Type: "string"})
+	if sc.Next(ctx) || sc.Err() == nil {
+		t.Fatal("expect err")
+	}
+	if sc.Next(ctx) {
+		t.Fatal("expect stopped")
+	}
+}
@@ -0,0 +1,52 @@
+package redisgo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+)
+
+// Script is a Lua script run by EVALSHA,
+// it falls back to EVAL if the script is not cached by redis yet.
+type Script struct {
+	keycount int
+	src      string
+	sha      string
+}
+
+// NewScript creates Script of src with keycount keys
+func NewScript(keycount int, src string) *Script {
+	h := sha1.Sum([]byte(src))
+	return &Script{keycount: keycount, src: src, sha: hex.EncodeToString(h[:])}
+}
+
+// Hash returns the SHA1 hex digest of the script
+func (s *Script) Hash() string {
+	return s.sha
+}
+
+// Load loads the script into the script cache of redis with SCRIPT LOAD
+func (s *Script) Load(c *Conn) error {
+	return c.DoNoReply("SCRIPT", "LOAD", s.src)
+}
+
+// Do runs the script with EVALSHA and falls back to EVAL if redis replies NOSCRIPT.
+// The first keycount elements of keysAndArgs are keys, and the rest are args.
+// Reply.Free() SHOULD be called when no longer used
+func (s *Script) Do(c *Conn, keysAndArgs ...interface{}) (*Reply, error) {
+	args := make([]interface{}, 0, len(keysAndArgs)+2)
+	args = append(args, s.sha, s.keycount)
+	args = append(args, keysAndArgs...)
+	reply, err := c.Do("EVALSHA", args...)
+	if err != nil {
+		return nil, err
+	}
+	var rerr RedisErr
+	if errors.As(reply.Err(), &rerr) && bytes.HasPrefix(rerr, []byte("NOSCRIPT")) {
+		reply.Free()
+		args[0] = s.src
+		return c.Do("EVAL", args...)
+	}
+	return reply, nil
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xiaost/redisgo/resp"
+)
+
+const (
+	stateActive int32 = iota
+	stateIdle
+	stateClosed
+)
+
+// Conn represents a client connection of Server
+type Conn struct {
+	s   *Server
+	nc  net.Conn
+	dec *resp.Decoder
+
+	mu       sync.Mutex // protects enc, handling and deferred
+	enc      *resp.Encoder
+	handling bool         // a handler is running, it writes enc without mu
+	deferred bytes.Buffer // written by Write while handling
+	denc     *resp.Encoder
+	state    int32
+
+	value   interface{}
+	closing bool
+}
+
+func newConn(s *Server, nc net.Conn) *Conn {
+	rbuf, wbuf := s.ReadBufferSize, s.WriteBufferSize
+	if rbuf <= 0 {
+		rbuf = 2048
+	}
+	if wbuf <= 0 {
+		wbuf = 2048
+	}
+	c := &Conn{
+		s:   s,
+		nc:  nc,
+		dec: resp.NewDecoderSize(nc, rbuf),
+		enc: resp.NewEncoderSize(nc, wbuf),
+	}
+	c.denc = resp.NewEncoder(&c.deferred)
+	c.dec.SetMaxLineLen(orint(s.MaxInlineSize, 64*1024))
+	c.dec.SetMaxBulkLen(orint(s.MaxBulkLen, 512*1024*1024))
+	c.dec.SetMaxArrayLen(orint(s.MaxArrayLen, 1024*1024))
+	return c
+}
+
+// orint returns v if it's > 0, or def
+func orint(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// NetConn returns the underlying net.Conn
+func (c *Conn) NetConn() net.Conn {
+	return c.nc
+}
+
+// RemoteAddr returns the remote network address
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.nc.RemoteAddr()
+}
+
+// Value returns the per connection state set by SetValue
+func (c *Conn) Value() interface{} {
+	return c.value
+}
+
+// SetValue sets the per connection state, it should only be called by handlers of the Conn
+func (c *Conn) SetValue(v interface{}) {
+	c.value = v
+}
+
+// Close closes the connection after the replies of the current request are written,
+// it should only be called by handlers of the Conn, like for QUIT.
+func (c *Conn) Close() {
+	c.closing = true
+}
+
+// Write calls fn with the ResponseWriter of the Conn and then flushes it.
+// It's safe for concurrent use, and is used for sending messages out of request,
+// like pub/sub messages.
+//
+// While a handler of the Conn is running, including Write called by the handler itself,
+// fn writes to a buffer which is sent right after the replies of the request,
+// so that messages never interleave with replies.
+func (c *Conn) Write(fn func(w ResponseWriter) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handling {
+		if err := fn(c.denc); err != nil {
+			return err
+		}
+		return c.denc.Flush()
+	}
+	if err := fn(c.enc); err != nil {
+		return err
+	}
+	return c.flush(c.enc.Flush)
+}
+
+// flush calls fn which writes to nc within WriteTimeout of Server
+func (c *Conn) flush(fn func() error) error {
+	if c.s.WriteTimeout <= 0 {
+		return fn()
+	}
+	c.nc.SetWriteDeadline(time.Now().Add(c.s.WriteTimeout))
+	err := fn()
+	c.nc.SetWriteDeadline(time.Time{}) // not for writes of handlers which may block for long
+	return err
+}
+
+func (c *Conn) close() {
+	atomic.StoreInt32(&c.state, stateClosed)
+	c.nc.Close()
+}
+
+func (c *Conn) serve() {
+	r := resp.NewReply()
+	defer r.Free()
+	for !c.closing {
+		if c.dec.Buffered() == 0 {
+			// all pipelined requests are handled, waiting for more
+			if !atomic.CompareAndSwapInt32(&c.state, stateActive, stateIdle) {
+				return
+			}
+			if c.s.IdleTimeout > 0 {
+				c.nc.SetReadDeadline(time.Now().Add(c.s.IdleTimeout))
+			}
+		}
+		err := c.dec.DecodeCommand(r)
+		if !atomic.CompareAndSwapInt32(&c.state, stateIdle, stateActive) &&
+			atomic.LoadInt32(&c.state) != stateActive {
+			return // closed by Shutdown
+		}
+		if err != nil {
+			if _, ok := err.(*resp.ProtocolError); ok {
+				c.Write(func(w ResponseWriter) error {
+					return w.WriteError("ERR Protocol error: " + err.Error())
+				})
+			}
+			return
+		}
+		aa, _ := r.Array()
+		req := &Request{Args: make([][]byte, len(aa)), Conn: c}
+		for i := range aa {
+			req.Args[i], _ = aa[i].Bytes()
+		}
+
+		c.mu.Lock()
+		c.handling = true
+		c.mu.Unlock()
+		c.s.Handler.ServeRESP(c.enc, req)
+		c.mu.Lock()
+		c.handling = false
+		if c.deferred.Len() > 0 {
+			err = c.flush(func() error {
+				if err := c.enc.Flush(); err != nil {
+					return err
+				}
+				_, err := c.nc.Write(c.deferred.Bytes())
+				return err
+			})
+			c.deferred.Reset()
+		} else if c.dec.Buffered() == 0 || c.closing {
+			err = c.flush(c.enc.Flush)
+		}
+		c.mu.Unlock()
+		if err != nil {
+			return
+		}
+		if c.s.isclosed() && c.dec.Buffered() == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"strings"
+	"sync"
+)
+
+// ServeMux dispatches requests to handlers by command name, names are case-insensitive
+type ServeMux struct {
+	mu sync.RWMutex
+	m  map[string]Handler
+
+	// NotFound handles commands which are not registered,
+	// "ERR unknown command" is replied if it's nil.
+	NotFound Handler
+}
+
+// NewServeMux creates a ServeMux
+func NewServeMux() *ServeMux {
+	return &ServeMux{m: make(map[string]Handler)}
+}
+
+// Handle registers h for command name
+func (mux *ServeMux) Handle(name string, h Handler) {
+	mux.mu.Lock()
+	mux.m[strings.ToUpper(name)] = h
+	mux.mu.Unlock()
+}
+
+// HandleFunc registers f for command name
+func (mux *ServeMux) HandleFunc(name string, f func(w ResponseWriter, r *Request)) {
+	mux.Handle(name, HandlerFunc(f))
+}
+
+// Handler returns the handler for r, nil is returned if not found
+func (mux *ServeMux) Handler(r *Request) Handler {
+	name := r.Name()
+	mux.mu.RLock()
+	h, ok := mux.m[name]
+	if !ok {
+		h = mux.m[strings.ToUpper(name)]
+	}
+	mux.mu.RUnlock()
+	return h
+}
+
+// ServeRESP dispatches r to the handler of its command name
+func (mux *ServeMux) ServeRESP(w ResponseWriter, r *Request) {
+	if h := mux.Handler(r); h != nil {
+		h.ServeRESP(w, r)
+		return
+	}
+	if mux.NotFound != nil {
+		mux.NotFound.ServeRESP(w, r)
+		return
+	}
+	w.WriteError("ERR unknown command '" + r.Name() + "'")
+}
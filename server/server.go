@@ -0,0 +1,255 @@
+// Package server implements a framework for servers speaking the redis protocol,
+// so that existing redis clients and tools are able to talk to them.
+//
+//	mux := server.NewServeMux()
+//	mux.HandleFunc("PING", func(w server.ResponseWriter, r *server.Request) {
+//		w.WriteSimpleString("PONG")
+//	})
+//	s := &server.Server{Handler: mux}
+//	s.Serve(l)
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xiaost/redisgo/resp"
+)
+
+// ErrServerClosed is returned by Server.Serve after a call to Shutdown or Close
+var ErrServerClosed = errors.New("server: closed")
+
+// ResponseWriter writes replies of a Request, it's implemented by *resp.Encoder.
+// Replies are flushed by the server after all pipelined requests are handled.
+type ResponseWriter interface {
+	WriteSimpleString(s string) error
+	WriteError(s string) error
+	WriteInteger(i int64) error
+	WriteBulk(b []byte) error
+	WriteBulkString(s string) error
+	WriteNil() error
+	WriteNilArray() error
+	WriteArrayHeader(n int) error
+	WriteNull() error
+	WriteDouble(f float64) error
+	WriteBool(v bool) error
+	WriteBigNumber(s string) error
+	WriteBlobError(s string) error
+	WriteVerbatim(format, s string) error
+	WriteMapHeader(n int) error
+	WriteSetHeader(n int) error
+	WritePushHeader(n int) error
+	WriteAttributeHeader(n int) error
+	WriteReply(r *resp.Reply) error
+}
+
+// Request represents a command received by the server
+type Request struct {
+	// Args contains the command name and its arguments,
+	// they must not be modified and are valid after the handler returned.
+	Args [][]byte
+
+	// Conn is the connection which the command is received from
+	Conn *Conn
+}
+
+// Name returns the command name
+func (r *Request) Name() string {
+	return string(r.Args[0])
+}
+
+// Handler responds to a Request
+type Handler interface {
+	ServeRESP(w ResponseWriter, r *Request)
+}
+
+// HandlerFunc adapts a function to Handler
+type HandlerFunc func(w ResponseWriter, r *Request)
+
+// ServeRESP calls f(w, r)
+func (f HandlerFunc) ServeRESP(w ResponseWriter, r *Request) {
+	f(w, r)
+}
+
+// Server serves redis protocol connections
+type Server struct {
+	// Handler handles all requests, it must not be nil
+	Handler Handler
+
+	// ReadBufferSize and WriteBufferSize are buffer sizes of connections, default: 2048
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// IdleTimeout closes connections which are idle for more than the duration if > 0
+	IdleTimeout time.Duration
+
+	// WriteTimeout limits the duration of flushing replies and messages to a connection if > 0,
+	// the connection is closed if it's exceeded, like a client which never reads.
+	WriteTimeout time.Duration
+
+	// MaxInlineSize limits the length of an inline command and a line of the protocol, default: 64KB.
+	MaxInlineSize int
+
+	// MaxBulkLen limits the length of an argument of commands, default: 512MB.
+	MaxBulkLen int
+
+	// MaxArrayLen limits the number of arguments of a command, default: 1M.
+	MaxArrayLen int
+
+	// OnConnect is called before serving a new connection if not nil
+	OnConnect func(c *Conn)
+
+	// OnDisconnect is called after a connection closed if not nil
+	OnDisconnect func(c *Conn)
+
+	mu        sync.Mutex
+	listeners map[*net.Listener]struct{}
+	conns     map[*Conn]struct{}
+	wg        sync.WaitGroup
+	closed    int32
+}
+
+func (s *Server) isclosed() bool {
+	return atomic.LoadInt32(&s.closed) != 0
+}
+
+// Serve accepts connections on l and serves each of them in a new goroutine.
+// Serve always closes l and returns a non-nil error, ErrServerClosed is returned after Shutdown or Close.
+// Errors of accepting are retried with backoff until l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	if !s.track(&l, true) {
+		return ErrServerClosed
+	}
+	defer s.track(&l, false)
+	var delay time.Duration
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if s.isclosed() {
+				return ErrServerClosed
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			// errors like EMFILE may go away, retries with backoff instead of stopping the server
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
+			}
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+		go s.ServeConn(nc)
+	}
+}
+
+func (s *Server) track(l *net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.isclosed() {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[*net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// ServeConn serves nc until it's closed, nc is closed when ServeConn returns.
+// it can be used for connections which are not from a listener like net.Pipe.
+func (s *Server) ServeConn(nc net.Conn) {
+	c := newConn(s, nc)
+	s.mu.Lock()
+	if s.isclosed() {
+		s.mu.Unlock()
+		nc.Close()
+		return
+	}
+	if s.conns == nil {
+		s.conns = make(map[*Conn]struct{})
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	defer func() {
+		c.close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		if s.OnDisconnect != nil {
+			s.OnDisconnect(c)
+		}
+		s.wg.Done()
+	}()
+	if s.OnConnect != nil {
+		s.OnConnect(c)
+	}
+	c.serve()
+}
+
+// Shutdown gracefully shuts down the server:
+// it closes all listeners, then closes idle connections after their pending replies are written,
+// and waits for active connections to become idle and then closes them.
+// If ctx is done before that, Shutdown closes all connections and returns ctx.Err().
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closelisteners()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.closeconns(false)
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			s.closeconns(true)
+			<-done
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close immediately closes all listeners and connections
+func (s *Server) Close() error {
+	s.closelisteners()
+	s.closeconns(true)
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) closelisteners() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	atomic.StoreInt32(&s.closed, 1)
+	for l := range s.listeners {
+		(*l).Close()
+	}
+}
+
+func (s *Server) closeconns(force bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if force || atomic.CompareAndSwapInt32(&c.state, stateIdle, stateClosed) {
+			c.nc.Close()
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/resp"
+)
+
+func TestServer(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("ping", func(w ResponseWriter, r *Request) {
+		w.WriteSimpleString("PONG")
+	})
+	mux.HandleFunc("INCR", func(w ResponseWriter, r *Request) {
+		// per connection counter
+		n, _ := r.Conn.Value().(int64)
+		n++
+		r.Conn.SetValue(n)
+		w.WriteInteger(n)
+	})
+	mux.HandleFunc("ECHO", func(w ResponseWriter, r *Request) {
+		if len(r.Args) != 2 {
+			w.WriteError("ERR wrong number of arguments")
+			return
+		}
+		w.WriteBulk(r.Args[1])
+	})
+	mux.HandleFunc("SLEEP", func(w ResponseWriter, r *Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteSimpleString("OK")
+	})
+	s := &Server{Handler: mux}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(l) }()
+
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := redisgo.NewConn(nc)
+	defer c.Close()
+
+	// pipelined
+	for i := 0; i < 3; i++ {
+		c.Send("INCR")
+	}
+	c.Send("ECHO", "hello")
+	c.Send("PING")
+	c.Send("NOTFOUND")
+	reply := redisgo.NewReply()
+	for i := 0; i < 3; i++ {
+		if err := c.Recv(reply); err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := reply.Integer(); n != int64(i+1) {
+			t.Fatal(n)
+		}
+	}
+	if c.Recv(reply); reply.Type() != resp.TypeBulkString {
+		t.Fatal(reply.Type())
+	}
+	if b, _ := reply.Bytes(); string(b) != "hello" {
+		t.Fatal(string(b))
+	}
+	if c.Recv(reply); reply.Type() != resp.TypeSimpleString {
+		t.Fatal(reply.Type())
+	}
+	if c.Recv(reply); reply.Err() == nil {
+		t.Fatal("expect err")
+	}
+
+	// inline command
+	nc2, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc2.Close()
+	nc2.Write([]byte("\r\nPING\r\nECHO  x\r\n"))
+	br := bufio.NewReader(nc2)
+	for _, expect := range []string{"+PONG\r\n", "$1\r\n", "x\r\n"} {
+		if line, _ := br.ReadString('\n'); line != expect {
+			t.Fatalf("%q", line)
+		}
+	}
+
+	// graceful shutdown waits for the active request
+	c.Send("SLEEP")
+	c.Flush()
+	time.Sleep(10 * time.Millisecond)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Recv(reply); err != nil || !reply.IsOK() {
+		t.Fatal(err)
+	}
+	reply.Free()
+	if err := <-served; err != ErrServerClosed {
+		t.Fatal(err)
+	}
+}
+
+func TestConnWriteInHandler(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("PING", func(w ResponseWriter, r *Request) {
+		// buffered and sent after the reply instead of deadlocking
+		err := r.Conn.Write(func(w ResponseWriter) error {
+			return w.WriteSimpleString("hello")
+		})
+		if err != nil {
+			w.WriteError("ERR " + err.Error())
+			return
+		}
+		w.WriteSimpleString("PONG")
+	})
+	s := &Server{Handler: mux}
+	defer s.Close()
+	c0, c1 := net.Pipe()
+	defer c0.Close()
+	go s.ServeConn(c1)
+
+	c0.SetDeadline(time.Now().Add(time.Second))
+	go c0.Write([]byte("PING\r\nPING\r\n"))
+	br := bufio.NewReader(c0)
+	for _, expect := range []string{"+PONG\r\n", "+hello\r\n", "+PONG\r\n", "+hello\r\n"} {
+		if line, err := br.ReadString('\n'); line != expect {
+			t.Fatalf("%q %v", line, err)
+		}
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	s := &Server{Handler: NewServeMux(), MaxInlineSize: 1024, MaxArrayLen: 10}
+	defer s.Close()
+	for _, req := range []string{
+		"SET k " + string(make([]byte, 4096)), // no CRLF
+		"*100\r\n",
+	} {
+		c0, c1 := net.Pipe()
+		go s.ServeConn(c1)
+		c0.SetDeadline(time.Now().Add(time.Second))
+		go c0.Write([]byte(req))
+		line, err := bufio.NewReader(c0).ReadString('\n')
+		if !strings.HasPrefix(line, "-ERR Protocol error") {
+			t.Fatalf("%q %v", line, err)
+		}
+		c0.Close()
+	}
+}
+
+func TestServerWriteTimeout(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("PING", func(w ResponseWriter, r *Request) {
+		w.WriteSimpleString("PONG")
+	})
+	s := &Server{Handler: mux, WriteTimeout: 20 * time.Millisecond}
+	defer s.Close()
+	c0, c1 := net.Pipe()
+	defer c0.Close()
+	done := make(chan struct{})
+	go func() {
+		s.ServeConn(c1)
+		close(done)
+	}()
+	c0.Write([]byte("PING\r\n")) // never reads the reply
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("conn not closed")
+	}
+}
+
+// errListener returns errors of Accept n times before a conn
+type errListener struct {
+	net.Listener
+	n int
+}
+
+func (l *errListener) Accept() (net.Conn, error) {
+	if l.n > 0 {
+		l.n--
+		return nil, errors.New("accept tcp: too many open files")
+	}
+	return l.Listener.Accept()
+}
+
+func TestServerAcceptErr(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("PING", func(w ResponseWriter, r *Request) {
+		w.WriteSimpleString("PONG")
+	})
+	s := &Server{Handler: mux}
+	defer s.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(&errListener{Listener: l, n: 3})
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := redisgo.NewConn(conn, redisgo.WithReadTimeout(time.Second))
+	defer c.Close()
+	if err := c.DoNoReply("PING"); err != nil {
+		t.Fatal(err)
+	}
+}
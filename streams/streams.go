@@ -0,0 +1,413 @@
+// Package streams implements workers of redis stream consumer groups.
+//
+// Worker reads messages with XREADGROUP, acks them after the handler succeeds,
+// and claims messages pending longer than the visibility timeout with XAUTOCLAIM,
+// so that messages of crashed consumers are delivered again. It requires redis 6.2 or later.
+//
+//	w := streams.NewWorker(pool, "orders", "billing", hostname)
+//	err := w.Run(ctx, func(ctx context.Context, msg streams.StreamMessage) error {
+//		return charge(msg.Fields["order"])
+//	})
+package streams
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/resp"
+)
+
+// StreamMessage represents an entry of a stream
+type StreamMessage struct {
+	ID     string
+	Fields map[string]string
+}
+
+// Handler processes a message, the message is acked if it returns nil,
+// or it's delivered again after the visibility timeout.
+type Handler func(ctx context.Context, msg StreamMessage) error
+
+// Fields added to messages moved to the dead letter stream, see WithDeadLetter
+const (
+	OriginIDField   = "origin-id"
+	DeliveriesField = "deliveries"
+)
+
+const (
+	// the read timeout of XREADGROUP is the BLOCK duration plus readmargin
+	readmargin = 5 * time.Second
+
+	// retrydelay is the delay before retrying after failures of reads
+	retrydelay = time.Second
+)
+
+// Worker processes messages of a stream as a consumer of a group
+type Worker struct {
+	p        *redisgo.Pool
+	stream   string
+	group    string
+	consumer string
+
+	count      int64
+	block      time.Duration
+	visibility time.Duration
+	start      string
+	deadletter string
+	maxdeliver int64
+	errfunc    func(err error)
+
+	lastclaim time.Time
+}
+
+// Option represents options of Worker
+type Option func(w *Worker)
+
+// WithCount sets the max number of messages of a read, default: 10.
+func WithCount(n int64) Option {
+	return func(w *Worker) {
+		w.count = n
+	}
+}
+
+// WithBlock sets the BLOCK duration of XREADGROUP, default: 5s.
+// Run doesn't return until the blocking read returns after ctx is done.
+// d is at least 1ms, since BLOCK 0 blocks forever.
+func WithBlock(d time.Duration) Option {
+	return func(w *Worker) {
+		w.block = max(d, time.Millisecond)
+	}
+}
+
+// WithVisibilityTimeout sets the idle time after which pending messages are claimed and delivered again,
+// they're checked every half of d. default: 1min.
+func WithVisibilityTimeout(d time.Duration) Option {
+	return func(w *Worker) {
+		w.visibility = d
+	}
+}
+
+// WithStartID sets the ID from which the group reads if it's created by Run, default: "$", new messages only.
+func WithStartID(id string) Option {
+	return func(w *Worker) {
+		w.start = id
+	}
+}
+
+// WithDeadLetter moves claimed messages delivered more than maxdeliver times to the stream,
+// with fields OriginIDField and DeliveriesField added. default: disabled, messages are delivered forever.
+func WithDeadLetter(stream string, maxdeliver int64) Option {
+	return func(w *Worker) {
+		w.deadletter = stream
+		w.maxdeliver = maxdeliver
+	}
+}
+
+// WithErrorFunc sets the func called with errors of handlers and redis, default: errors are ignored.
+func WithErrorFunc(fn func(err error)) Option {
+	return func(w *Worker) {
+		w.errfunc = fn
+	}
+}
+
+// NewWorker creates Worker of consumer in group of stream
+func NewWorker(p *redisgo.Pool, stream, group, consumer string, ops ...Option) *Worker {
+	w := &Worker{
+		p:          p,
+		stream:     stream,
+		group:      group,
+		consumer:   consumer,
+		count:      10,
+		block:      5 * time.Second,
+		visibility: time.Minute,
+		start:      "$",
+	}
+	for _, op := range ops {
+		op(w)
+	}
+	return w
+}
+
+// CreateGroup creates group of stream reading from start with XGROUP CREATE ... MKSTREAM,
+// it returns nil if the group already exists.
+func CreateGroup(ctx context.Context, p *redisgo.Pool, stream, group, start string) error {
+	conn, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	err = conn.DoNoReply("XGROUP", "CREATE", stream, group, start, "MKSTREAM")
+	var rerr redisgo.RedisErr
+	if errors.As(err, &rerr) && bytes.HasPrefix(rerr, []byte("BUSYGROUP")) {
+		return nil
+	}
+	return err
+}
+
+// Run creates the group if not exists and processes messages with h until ctx is done,
+// it returns ctx.Err() or the error of creating the group.
+func (w *Worker) Run(ctx context.Context, h Handler) error {
+	if err := CreateGroup(ctx, w.p, w.stream, w.group, w.start); err != nil {
+		return err
+	}
+	for ctx.Err() == nil {
+		if time.Since(w.lastclaim) >= w.visibility/2 {
+			w.lastclaim = time.Now()
+			if err := w.Claim(ctx, h); err != nil {
+				w.error(err)
+			}
+		}
+		msgs, err := w.Read(ctx)
+		if err != nil {
+			w.error(err)
+			sleep(ctx, retrydelay)
+			continue
+		}
+		for _, msg := range msgs {
+			w.handle(ctx, h, msg)
+		}
+	}
+	return ctx.Err()
+}
+
+// Read reads new messages for the consumer with XREADGROUP ... BLOCK,
+// it returns no messages if nothing arrived within the BLOCK duration.
+func (w *Worker) Read(ctx context.Context) ([]StreamMessage, error) {
+	conn, err := w.p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	reply, err := conn.DoTimeout(w.block+readmargin, "XREADGROUP", "GROUP", w.group, w.consumer,
+		"COUNT", w.count, "BLOCK", w.block.Milliseconds(), "STREAMS", w.stream, ">")
+	if err != nil {
+		return nil, err
+	}
+	defer reply.Free()
+	if reply.IsNil() { // timed out
+		return nil, nil
+	}
+	streams, err := reply.Array()
+	if err != nil {
+		return nil, err
+	}
+	var msgs []StreamMessage
+	for i := range streams {
+		// [stream, messages]
+		ss, err := streams[i].Array()
+		if err != nil {
+			return nil, err
+		}
+		if len(ss) != 2 {
+			return nil, errors.New("streams: unexpected reply of XREADGROUP")
+		}
+		m, err := parsemessages(&ss[1])
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m...)
+	}
+	return msgs, nil
+}
+
+// Claim claims messages idle longer than the visibility timeout with XAUTOCLAIM and processes them with h,
+// messages delivered too many times are moved to the dead letter stream if configured.
+func (w *Worker) Claim(ctx context.Context, h Handler) error {
+	cursor := "0-0"
+	for ctx.Err() == nil {
+		msgs, deliveries, next, err := w.claim(ctx, cursor)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			if msg.Fields == nil { // deleted from the stream
+				w.ack(ctx, msg.ID)
+			} else if n := deliveries[msg.ID]; w.maxdeliver > 0 && n > w.maxdeliver {
+				if err := w.bury(ctx, msg, n); err != nil {
+					w.error(err)
+				}
+			} else {
+				w.handle(ctx, h, msg)
+			}
+		}
+		if cursor = next; cursor == "0-0" {
+			break
+		}
+	}
+	return nil
+}
+
+// claim runs XAUTOCLAIM from cursor, and gets delivery counts of claimed messages if dead letter is enabled
+func (w *Worker) claim(ctx context.Context, cursor string) (msgs []StreamMessage, deliveries map[string]int64, next string, err error) {
+	conn, err := w.p.Get(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reply, err := conn.Do("XAUTOCLAIM", w.stream, w.group, w.consumer, w.visibility.Milliseconds(), cursor, "COUNT", w.count)
+	if err != nil {
+		return
+	}
+	defer reply.Free()
+	aa, err := reply.Array()
+	if err != nil {
+		return
+	}
+	if len(aa) < 2 {
+		err = errors.New("streams: unexpected reply of XAUTOCLAIM")
+		return
+	}
+	if next, err = aa[0].Str(); err != nil {
+		return
+	}
+	if msgs, err = parsemessages(&aa[1]); err != nil || len(msgs) == 0 || w.maxdeliver <= 0 {
+		return
+	}
+	deliveries, err = w.deliveries(conn.Conn, msgs)
+	return
+}
+
+// deliveries returns delivery counts of msgs with XPENDING of each ID in a pipeline,
+// a range of IDs may contain other pending messages which push out the claimed ones.
+func (w *Worker) deliveries(conn *redisgo.Conn, msgs []StreamMessage) (map[string]int64, error) {
+	n := 0
+	for _, msg := range msgs {
+		if msg.Fields == nil { // deleted, acked without counting
+			continue
+		}
+		if err := conn.Send("XPENDING", w.stream, w.group, msg.ID, msg.ID, 1); err != nil {
+			return nil, err
+		}
+		n++
+	}
+	ret := make(map[string]int64, n)
+	var reply redisgo.Reply
+	var err error
+	for i := 0; i < n; i++ { // receives all replies even if failed, so that the conn is reusable
+		if e := conn.Recv(&reply); e != nil {
+			return nil, e
+		}
+		if e := parsepending(&reply, ret); e != nil && err == nil {
+			err = e
+		}
+	}
+	return ret, err
+}
+
+// parsepending adds the delivery count of the reply of XPENDING to m
+func parsepending(r *redisgo.Reply, m map[string]int64) error {
+	aa, err := r.Array()
+	if err != nil {
+		return err
+	}
+	for i := range aa {
+		// [id, consumer, idle, deliveries]
+		ee, err := aa[i].Array()
+		if err != nil {
+			return err
+		}
+		if len(ee) < 4 {
+			return errors.New("streams: unexpected reply of XPENDING")
+		}
+		id, err := ee[0].Str()
+		if err != nil {
+			return err
+		}
+		if m[id], err = ee[3].Integer(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle calls h with msg, and acks msg if h succeeds
+func (w *Worker) handle(ctx context.Context, h Handler, msg StreamMessage) {
+	if err := h(ctx, msg); err != nil {
+		w.error(err)
+		return
+	}
+	w.ack(ctx, msg.ID)
+}
+
+func (w *Worker) ack(ctx context.Context, id string) {
+	conn, err := w.p.Get(ctx)
+	if err != nil {
+		w.error(err)
+		return
+	}
+	defer conn.Close()
+	if err := conn.DoNoReply("XACK", w.stream, w.group, id); err != nil {
+		w.error(err)
+	}
+}
+
+// bury adds msg to the dead letter stream and acks it
+func (w *Worker) bury(ctx context.Context, msg StreamMessage, deliveries int64) error {
+	conn, err := w.p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	args := make([]interface{}, 0, 2*len(msg.Fields)+6)
+	args = append(args, w.deadletter, "*")
+	for _, k := range slices.Sorted(maps.Keys(msg.Fields)) {
+		args = append(args, k, msg.Fields[k])
+	}
+	args = append(args, OriginIDField, msg.ID, DeliveriesField, deliveries)
+	if err := conn.DoNoReply("XADD", args...); err != nil {
+		return err
+	}
+	return conn.DoNoReply("XACK", w.stream, w.group, msg.ID)
+}
+
+func (w *Worker) error(err error) {
+	if w.errfunc != nil && !errors.Is(err, context.Canceled) {
+		w.errfunc(err)
+	}
+}
+
+// parsemessages decodes messages replied as [[id, [field, value, ...]], ...],
+// Fields is nil if the message was deleted.
+func parsemessages(r *redisgo.Reply) ([]StreamMessage, error) {
+	aa, err := r.Array()
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]StreamMessage, 0, len(aa))
+	for i := range aa {
+		ee, err := aa[i].Array()
+		if err != nil {
+			return nil, err
+		}
+		if len(ee) != 2 {
+			return nil, errors.New("streams: unexpected message of stream")
+		}
+		var msg StreamMessage
+		if msg.ID, err = ee[0].Str(); err != nil {
+			return nil, err
+		}
+		if !ee[1].IsNil() && ee[1].Type() != resp.TypeNilArray {
+			if msg.Fields, err = ee[1].StringMap(); err != nil {
+				return nil, err
+			}
+			if msg.Fields == nil {
+				msg.Fields = map[string]string{}
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
@@ -0,0 +1,176 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xiaost/redisgo"
+	"github.com/xiaost/redisgo/redistest"
+)
+
+func TestCreateGroup(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	p := redisgo.NewPool(m.Dial)
+	ctx := context.Background()
+
+	m.Expect("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM").Return(redistest.OK)
+	m.Expect("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM").ReturnError("BUSYGROUP Consumer Group name already exists")
+	m.Expect("XGROUP", "CREATE", "s", "g", "$", "MKSTREAM").ReturnError("WRONGTYPE Key is not a stream")
+	for i, expecterr := range []bool{false, false, true} {
+		if err := CreateGroup(ctx, p, "s", "g", "$"); (err != nil) != expecterr {
+			t.Fatal(i, err)
+		}
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m.Expect("XGROUP", "CREATE", "s", "g", "0", "MKSTREAM").ReturnError("BUSYGROUP Consumer Group name already exists")
+	m.Expect("XAUTOCLAIM", "s", "g", "c", 60000, "0-0", "COUNT", 10).Return([]interface{}{"0-0", []interface{}{}, []interface{}{}})
+	m.Expect("XREADGROUP", "GROUP", "g", "c", "COUNT", 10, "BLOCK", 5000, "STREAMS", "s", ">").Return(nil)
+	m.Expect("XREADGROUP", "GROUP", "g", "c", "COUNT", 10, "BLOCK", 5000, "STREAMS", "s", ">").Return([]interface{}{
+		[]interface{}{"s", []interface{}{
+			[]interface{}{"1-0", []string{"a", "1"}},
+			[]interface{}{"2-0", []string{"a", "2"}},
+		}},
+	})
+	m.Expect("XACK", "s", "g", "1-0").Return(1)
+
+	var got []StreamMessage
+	var errs []error
+	w := NewWorker(redisgo.NewPool(m.Dial), "s", "g", "c", WithStartID("0"), WithErrorFunc(func(err error) {
+		errs = append(errs, err)
+	}))
+	err := w.Run(ctx, func(ctx context.Context, msg StreamMessage) error {
+		got = append(got, msg)
+		if msg.ID == "2-0" {
+			cancel()
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err != context.Canceled {
+		t.Fatal(err)
+	}
+	expect := []StreamMessage{{"1-0", map[string]string{"a": "1"}}, {"2-0", map[string]string{"a": "2"}}}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatal(got)
+	}
+	if len(errs) != 1 || errs[0].Error() != "failed" {
+		t.Fatal(errs)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkerClaim(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	ctx := context.Background()
+
+	m.Expect("XAUTOCLAIM", "s", "g", "c", 1000, "0-0", "COUNT", 10).Return([]interface{}{"5-0",
+		[]interface{}{
+			[]interface{}{"1-0", []string{"b", "1", "a", "1"}},
+			[]interface{}{"2-0", nil}, // deleted
+		},
+		[]interface{}{},
+	})
+	m.Expect("XPENDING", "s", "g", "1-0", "1-0", 1).Return([]interface{}{
+		[]interface{}{"1-0", "c", int64(1000), int64(3)},
+	})
+	m.Expect("XADD", "dead", "*", "a", "1", "b", "1", OriginIDField, "1-0", DeliveriesField, 3).Return("9-0")
+	m.Expect("XACK", "s", "g", "1-0").Return(1)
+	m.Expect("XACK", "s", "g", "2-0").Return(1)
+	m.Expect("XAUTOCLAIM", "s", "g", "c", 1000, "5-0", "COUNT", 10).Return([]interface{}{"0-0",
+		[]interface{}{[]interface{}{"6-0", []string{"a", "2"}}},
+	})
+	m.Expect("XPENDING", "s", "g", "6-0", "6-0", 1).Return([]interface{}{
+		[]interface{}{"6-0", "c", int64(1000), int64(2)},
+	})
+	m.Expect("XACK", "s", "g", "6-0").Return(1)
+
+	w := NewWorker(redisgo.NewPool(m.Dial), "s", "g", "c", WithVisibilityTimeout(time.Second), WithDeadLetter("dead", 2))
+	var got []string
+	err := w.Claim(ctx, func(ctx context.Context, msg StreamMessage) error {
+		got = append(got, msg.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"6-0"}) {
+		t.Fatal(got)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkerBlock(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	p := redisgo.NewPool(m.Dial)
+	ctx := context.Background()
+
+	for _, d := range []time.Duration{0, -time.Second, time.Microsecond, 1500 * time.Millisecond} {
+		expect := max(d.Milliseconds(), 1)
+		m.Expect("XREADGROUP", "GROUP", "g", "c", "COUNT", 10, "BLOCK", expect, "STREAMS", "s", ">").Return(nil)
+		msgs, err := NewWorker(p, "s", "g", "c", WithBlock(d)).Read(ctx)
+		if err != nil || msgs != nil {
+			t.Fatal(d, msgs, err)
+		}
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkerClaimInterleaved(t *testing.T) {
+	m := redistest.NewMock()
+	defer m.Close()
+	ctx := context.Background()
+
+	// 2-0 is pending for the consumer but not idle long enough to be claimed,
+	// the delivery counts of the claimed messages are read by their IDs
+	m.Expect("XAUTOCLAIM", "s", "g", "c", 1000, "0-0", "COUNT", 10).Return([]interface{}{"0-0",
+		[]interface{}{
+			[]interface{}{"1-0", []string{"a", "1"}},
+			[]interface{}{"3-0", []string{"a", "3"}},
+		},
+		[]interface{}{},
+	})
+	m.Expect("XPENDING", "s", "g", "1-0", "1-0", 1).Return([]interface{}{
+		[]interface{}{"1-0", "c", int64(1000), int64(2)},
+	})
+	m.Expect("XPENDING", "s", "g", "3-0", "3-0", 1).Return([]interface{}{
+		[]interface{}{"3-0", "c", int64(1000), int64(5)},
+	})
+	m.Expect("XACK", "s", "g", "1-0").Return(1)
+	m.Expect("XADD", "dead", "*", "a", "3", OriginIDField, "3-0", DeliveriesField, 5).Return("9-0")
+	m.Expect("XACK", "s", "g", "3-0").Return(1)
+
+	w := NewWorker(redisgo.NewPool(m.Dial), "s", "g", "c", WithVisibilityTimeout(time.Second), WithDeadLetter("dead", 2))
+	var got []string
+	err := w.Claim(ctx, func(ctx context.Context, msg StreamMessage) error {
+		got = append(got, msg.ID)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(got, []string{"1-0"}) {
+		t.Fatal(got, err)
+	}
+	if err := m.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,60 @@
+package redisgo
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errNegativeTTL = errors.New("redisgo: negative ttl")
+
+// TypedClient gets and sets values of T encoded by a Codec with conns of a Pool
+type TypedClient[T any] struct {
+	p     *Pool
+	codec Codec
+}
+
+// NewTypedClient creates TypedClient with p and codec
+func NewTypedClient[T any](p *Pool, codec Codec) *TypedClient[T] {
+	return &TypedClient[T]{p: p, codec: codec}
+}
+
+// Get returns the value of key, ErrNil is returned if key not exists.
+// ctx is passed to Pool.Get
+func (c *TypedClient[T]) Get(ctx context.Context, key string) (T, error) {
+	var v T
+	conn, err := c.p.Get(ctx)
+	if err != nil {
+		return v, err
+	}
+	defer conn.Close()
+	b, err := conn.DoBytes("GET", key)
+	if err != nil {
+		return v, err
+	}
+	err = c.codec.Unmarshal(b, &v)
+	return v, err
+}
+
+// Set sets the value of key with ttl, the key never expires if ttl is 0,
+// and an error is returned if ttl < 0.
+// ctx is passed to Pool.Get
+func (c *TypedClient[T]) Set(ctx context.Context, key string, v T, ttl time.Duration) error {
+	if ttl < 0 {
+		return errNegativeTTL
+	}
+	b, err := c.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	conn, err := c.p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if ttl > 0 {
+		ms := (ttl + time.Millisecond - 1) / time.Millisecond // rounds up for ttl < 1ms
+		return conn.DoNoReply("SET", key, b, "PX", int64(ms))
+	}
+	return conn.DoNoReply("SET", key, b)
+}